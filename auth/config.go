@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config controls the tokens a JWTService issues and accepts
+type Config struct {
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// ConfigFromEnv reads JWT_ISSUER, JWT_ACCESS_TTL, JWT_REFRESH_TTL and
+// JWT_LEEWAY, using defaults for any that are unset. Durations use Go syntax,
+// e.g. "15m" or "168h".
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Issuer:     "hustle-backend",
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 7 * 24 * time.Hour,
+		Leeway:     30 * time.Second,
+	}
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		cfg.Issuer = v
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"JWT_ACCESS_TTL", &cfg.AccessTTL},
+		{"JWT_REFRESH_TTL", &cfg.RefreshTTL},
+		{"JWT_LEEWAY", &cfg.Leeway},
+	}
+	for _, d := range durations {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return Config{}, fmt.Errorf("invalid %s %q", d.env, v)
+		}
+		*d.dst = parsed
+	}
+
+	if cfg.AccessTTL <= 0 || cfg.RefreshTTL <= cfg.AccessTTL {
+		return Config{}, fmt.Errorf("JWT_ACCESS_TTL must be positive and shorter than JWT_REFRESH_TTL")
+	}
+	return cfg, nil
+}
@@ -1,131 +1,187 @@
 package auth
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"os"
+	"slices"
 	"time"
-	"log"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Access and refresh tokens are told apart by their token_use claim and
+// audience, so neither can be submitted in place of the other
+const (
+	accessTokenUse  = "access"
+	refreshTokenUse = "refresh"
+	accessAudience  = "hustle-api"
+	refreshAudience = "hustle-refresh"
+)
+
+// ErrWrongTokenType is returned when a valid token of the other type is
+// presented, e.g. a refresh token used as a bearer credential
+var ErrWrongTokenType = errors.New("wrong token type")
+
 type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
+	TokenUse  string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// RefreshClaims identify the session a refresh token belongs to. Each token
+// gets a unique ID so rotated tokens never repeat.
+type RefreshClaims struct {
+	SessionID uint   `json:"sid"`
+	TokenUse  string `json:"token_use"`
 	jwt.RegisteredClaims
 }
 
 type TokenPair struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int64  `json:"expires_in"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresIn        int64     `json:"expires_in"`
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
 type JWTService struct {
-	secretKey []byte
+	config Config
+	keys   *KeyRing
 }
 
-func NewJWTService() *JWTService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		log.Println("[WARN] JWT_SECRET environment variable not set! Using default value.")
-		secret = "default-secret-key-change-in-production"
-	}
+// NewJWTService creates the service shared by every handler; construct it
+// once at startup
+func NewJWTService(config Config, keys *KeyRing) *JWTService {
 	return &JWTService{
-		secretKey: []byte(secret),
+		config: config,
+		keys:   keys,
 	}
 }
 
-// GenerateTokenPair creates both access and refresh tokens
-func (j *JWTService) GenerateTokenPair(userID, email string) (*TokenPair, error) {
-	// Access token expires in 15 minutes
-	accessToken, err := j.generateAccessToken(userID, email)
+// AccessTTL is how long access tokens are valid for
+func (j *JWTService) AccessTTL() time.Duration {
+	return j.config.AccessTTL
+}
+
+// GenerateTokenPair creates both access and refresh tokens for a session
+func (j *JWTService) GenerateTokenPair(userID, email, role string, sessionID uint) (*TokenPair, error) {
+	now := time.Now()
+
+	accessToken, err := j.generateAccessToken(userID, email, role, sessionID, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %v", err)
 	}
 
-	// Refresh token expires in 7 days
-	refreshToken, err := j.generateRefreshToken(userID)
+	refreshExpiresAt := now.Add(j.config.RefreshTTL)
+	refreshToken, err := j.generateRefreshToken(userID, sessionID, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %v", err)
 	}
 
-	expiresIn := int64(15 * 60) // 15 minutes in seconds
-
 	return &TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    expiresIn,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int64(j.config.AccessTTL.Seconds()),
+		RefreshExpiresAt: refreshExpiresAt,
 	}, nil
 }
 
-// generateAccessToken creates a short-lived access token
-func (j *JWTService) generateAccessToken(userID, email string) (string, error) {
+// generateAccessToken creates a short-lived access token. The role claim is
+// informational for clients; authorization checks use the stored role so
+// changes apply immediately.
+func (j *JWTService) generateAccessToken(userID, email, role string, sessionID uint, now time.Time) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
+		TokenUse:  accessTokenUse,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "hustle-backend",
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.config.AccessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    j.config.Issuer,
+			Audience:  jwt.ClaimStrings{accessAudience},
 			Subject:   userID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secretKey)
+	return j.keys.sign(claims)
 }
 
 // generateRefreshToken creates a long-lived refresh token
-func (j *JWTService) generateRefreshToken(userID string) (string, error) {
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		Issuer:    "hustle-backend",
-		Subject:   userID,
+func (j *JWTService) generateRefreshToken(userID string, sessionID uint, now time.Time) (string, error) {
+	claims := RefreshClaims{
+		SessionID: sessionID,
+		TokenUse:  refreshTokenUse,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.config.RefreshTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    j.config.Issuer,
+			Audience:  jwt.ClaimStrings{refreshAudience},
+			Subject:   userID,
+			ID:        rand.Text(),
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secretKey)
+	return j.keys.sign(claims)
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates an access token and returns the claims. A valid
+// refresh token is rejected with ErrWrongTokenType.
 func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secretKey, nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %v", err)
+	claims := &JWTClaims{}
+	if err := j.parse(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	if err := checkType(claims.TokenUse, claims.Audience, accessTokenUse, accessAudience); err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
-// ValidateRefreshToken validates a refresh token
-func (j *JWTService) ValidateRefreshToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secretKey, nil
-	})
-
-	if err != nil {
-		return "", fmt.Errorf("failed to parse refresh token: %v", err)
+// ValidateRefreshToken validates a refresh token and returns its claims. A
+// valid access token is rejected with ErrWrongTokenType.
+func (j *JWTService) ValidateRefreshToken(tokenString string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := j.parse(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("failed to parse refresh token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		return claims.Subject, nil
+	if err := checkType(claims.TokenUse, claims.Audience, refreshTokenUse, refreshAudience); err != nil {
+		return nil, err
+	}
+	if claims.SessionID == 0 {
+		return nil, fmt.Errorf("invalid refresh token")
 	}
 
-	return "", fmt.Errorf("invalid refresh token")
+	return claims, nil
+}
+
+// parse verifies the signature, issuer and validity window of a token
+func (j *JWTService) parse(tokenString string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, j.keys.keyFunc,
+		jwt.WithValidMethods(j.keys.validMethods()),
+		jwt.WithIssuer(j.config.Issuer),
+		jwt.WithLeeway(j.config.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+// checkType makes sure a verified token is of the expected kind
+func checkType(tokenUse string, audience jwt.ClaimStrings, wantUse, wantAudience string) error {
+	if tokenUse != wantUse {
+		return ErrWrongTokenType
+	}
+	if !slices.Contains(audience, wantAudience) {
+		return fmt.Errorf("invalid token audience")
+	}
+	return nil
 }
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verificationKey is a public key tokens may be signed with
+type verificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeyRing holds the key new tokens are signed with plus every key tokens are
+// still accepted from. To rotate without downtime, make the new key the
+// signing key and keep the old one as a verification key until every token
+// it signed has expired.
+type KeyRing struct {
+	signingID  string
+	signer     crypto.Signer
+	method     jwt.SigningMethod
+	verifiers  map[string]verificationKey
+	verifyList []verificationKey // in load order, for JWKS
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// allowEphemeralKey reports whether a throwaway signing key may be used when
+// none is configured. It must be asked for, so a deploy missing its key
+// fails to start instead of logging everyone out on every restart.
+func allowEphemeralKey() bool {
+	return os.Getenv("APP_ENV") == "development" || os.Getenv("JWT_ALLOW_EPHEMERAL_KEY") == "true"
+}
+
+// LoadKeyRing loads the JWT keys.
+//
+// JWT_SIGNING_KEY_FILE is a PEM Ed25519 or RSA private key that new tokens
+// are signed with. JWT_VERIFICATION_KEY_FILES is an optional comma-separated
+// list of PEM public (or private) keys that are still accepted, e.g. the
+// previous signing key during a rotation. Without a signing key, a
+// throwaway one is generated only if APP_ENV is "development" or
+// JWT_ALLOW_EPHEMERAL_KEY is "true"; otherwise loading fails.
+func LoadKeyRing() (*KeyRing, error) {
+	ring := &KeyRing{verifiers: make(map[string]verificationKey)}
+
+	signingPath := os.Getenv("JWT_SIGNING_KEY_FILE")
+	if signingPath == "" {
+		if !allowEphemeralKey() {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE must be set (or APP_ENV=development for a temporary key)")
+		}
+		log.Println("[WARN] JWT_SIGNING_KEY_FILE not set! Using a temporary key; tokens will not survive a restart.")
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate temporary signing key: %v", err)
+		}
+		if err := ring.setSigner(private); err != nil {
+			return nil, err
+		}
+	} else {
+		key, err := readPEMKey(signingPath)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a private key", signingPath)
+		}
+		if err := ring.setSigner(signer); err != nil {
+			return nil, fmt.Errorf("%s: %v", signingPath, err)
+		}
+	}
+
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := readPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			key = signer.Public()
+		}
+		if err := ring.addVerifier(key); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return ring, nil
+}
+
+func (k *KeyRing) setSigner(signer crypto.Signer) error {
+	if err := k.addVerifier(signer.Public()); err != nil {
+		return err
+	}
+	v := k.verifyList[len(k.verifyList)-1]
+	k.signingID, k.signer, k.method = v.id, signer, v.method
+	return nil
+}
+
+func (k *KeyRing) addVerifier(public crypto.PublicKey) error {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	default:
+		return fmt.Errorf("unsupported key type %T; use Ed25519 or RSA", public)
+	}
+
+	id, err := keyID(public)
+	if err != nil {
+		return err
+	}
+	if _, ok := k.verifiers[id]; ok {
+		return nil
+	}
+	v := verificationKey{id: id, method: method, public: public}
+	k.verifiers[id] = v
+	k.verifyList = append(k.verifyList, v)
+	return nil
+}
+
+// keyID derives a stable kid from the public key, so it never needs to be
+// configured separately
+func keyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+func readPEMKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+}
+
+// sign signs claims with the current signing key, naming it in the kid header
+func (k *KeyRing) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.signingID
+	return token.SignedString(k.signer)
+}
+
+// keyFunc finds the verification key named by a token's kid header
+func (k *KeyRing) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.verifiers[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// validMethods are the algorithms tokens may be signed with
+func (k *KeyRing) validMethods() []string {
+	return []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}
+}
+
+// JWKS returns every verification key as a JSON Web Key Set
+func (k *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(k.verifyList))}
+	for _, v := range k.verifyList {
+		jwk := JWK{Kid: v.id, Use: "sig", Alg: v.method.Alg()}
+		switch public := v.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
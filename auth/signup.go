@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"os"
+	"strings"
+)
+
+// SignupPolicy decides which Firebase accounts may create a user
+type SignupPolicy struct {
+	// Domains are the email domains anyone may sign up with; other addresses
+	// need an allowlist entry
+	Domains []string
+}
+
+// SignupPolicyFromEnv reads ALLOWED_EMAIL_DOMAINS, a comma-separated list of
+// email domains that defaults to cornell.edu
+func SignupPolicyFromEnv() SignupPolicy {
+	var policy SignupPolicy
+	for _, domain := range strings.Split(os.Getenv("ALLOWED_EMAIL_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			policy.Domains = append(policy.Domains, domain)
+		}
+	}
+	if len(policy.Domains) == 0 {
+		policy.Domains = []string{"cornell.edu"}
+	}
+	return policy
+}
+
+// AllowsDomain reports whether email belongs to one of the allowed domains.
+// Subdomains are not included.
+func (p SignupPolicy) AllowsDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range p.Domains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
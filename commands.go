@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/migrations"
+	"github.com/cuappdev/hustle-backend/models"
+	"gorm.io/gorm"
+)
+
+const usage = `usage: hustle-backend [command]
+
+With no command, starts the server.
+
+Commands:
+  migrate up          apply all pending migrations
+  migrate down [n]    roll back the last n migrations (default 1)
+  migrate status      list migrations and whether they are applied
+  role set EMAIL ROLE give a user the user, moderator or admin role`
+
+// runCommand runs a CLI subcommand and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "role":
+		return runRole(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+}
+
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	if err := models.ConnectDatabase(); err != nil {
+		log.Printf("[FATAL] Database connection failed: %v", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(models.DB)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, usage)
+				return 2
+			}
+			steps = n
+		}
+		rolledBack, err := migrations.Down(models.DB, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			return 1
+		}
+	case "status":
+		statuses, err := migrations.Statuses(models.DB)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	return 0
+}
+
+// runRole seeds roles, e.g. the first admin, who can then manage roles
+// through the API
+func runRole(args []string) int {
+	if len(args) != 3 || args[0] != "set" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	email, role := args[1], args[2]
+	if !slices.Contains([]string{models.RoleUser, models.RoleModerator, models.RoleAdmin}, role) {
+		fmt.Fprintf(os.Stderr, "unknown role %q\n", role)
+		return 2
+	}
+
+	if err := models.ConnectDatabase(); err != nil {
+		log.Printf("[FATAL] Database connection failed: %v", err)
+		return 1
+	}
+	user, err := models.FindUserByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Fprintf(os.Stderr, "no user with email %s; they must sign in first\n", email)
+		return 1
+	}
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return 1
+	}
+	if err := user.SetRole(role); err != nil {
+		log.Printf("[ERROR] %v", err)
+		return 1
+	}
+	fmt.Printf("%s is now %s\n", email, role)
+	return 0
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET /admin/allowlist
+// Get the email addresses allowed to sign up outside the allowed domains
+func FindAllowedEmails(c *gin.Context) {
+	entries, err := models.FindAllowedEmails()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allowlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+// POST /admin/allowlist
+// Allow an email address to sign up
+func CreateAllowedEmail(c *gin.Context) {
+	var input models.CreateAllowedEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := models.CreateAllowedEmail(input, middleware.CurrentUser(c).ID)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already on the allowlist"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allowlist"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": entry})
+}
+
+// DELETE /admin/allowlist/:id
+// Remove an email address from the allowlist
+func DeleteAllowedEmail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid allowlist id"})
+		return
+	}
+
+	err = models.DeleteAllowedEmail(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Allowlist entry not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allowlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from allowlist"})
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/cuappdev/hustle-backend/realtime"
+	"github.com/cuappdev/hustle-backend/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const defaultMessagePageSize = 30
+
+// GET /conversations
+// Get the current user's conversations with unread counts
+func FindConversations(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	conversations, err := models.FindUserConversations(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
+		return
+	}
+
+	var unread int64
+	for _, conversation := range conversations {
+		unread += conversation.UnreadCount
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": conversations, "unread_count": unread})
+}
+
+// GET /conversations/unread
+// Get the number of unread messages across all conversations
+func CountUnreadMessages(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	unread, err := models.CountUnreadMessages(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+// POST /conversations
+// Start (or get the existing) conversation with another user
+func StartConversation(c *gin.Context) {
+	var input models.StartConversationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if input.RecipientID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot message yourself"})
+		return
+	}
+
+	if err := models.DB.First(&models.User{}, input.RecipientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	conversation, err := models.FindOrCreateConversation(user.ID, input.RecipientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": conversation})
+}
+
+// GET /conversations/:id/messages?cursor=<cursor>&limit=<n>
+// Page through a conversation's messages, newest first
+func FindMessages(c *gin.Context) {
+	page := models.PageInput{Limit: defaultMessagePageSize}
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, ok := loadConversation(c)
+	if !ok {
+		return
+	}
+
+	messages, next, err := conversation.Messages(page)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	var nextCursor *string
+	if next != "" {
+		nextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages, "next_cursor": nextCursor})
+}
+
+// POST /conversations/:id/messages
+// Send a message and push it to the other member
+func SendMessage(notifications *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.SendMessageInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		conversation, ok := loadConversation(c)
+		if !ok {
+			return
+		}
+
+		user := middleware.CurrentUser(c)
+		recipientID := conversation.OtherMember(user.ID)
+		message, err := conversation.SendMessage(user.ID, input.Body, func(tx *gorm.DB, message *models.Message) error {
+			payload := services.NotificationPayload{
+				Category: models.CategoryMessages,
+				Title:    user.FirstName,
+				Body:     message.Body,
+				Data: map[string]string{
+					"type":            "message",
+					"conversation_id": strconv.FormatUint(uint64(conversation.ID), 10),
+					"message_id":      strconv.FormatUint(uint64(message.ID), 10),
+				},
+			}
+			return notifications.Notify(tx, recipientID, fmt.Sprintf("message:%d", message.ID), payload)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			return
+		}
+
+		// The sender gets it too so their other devices stay in sync
+		notifications.Publish(recipientID, realtime.EventMessage, message)
+		notifications.Publish(user.ID, realtime.EventMessage, message)
+
+		c.JSON(http.StatusCreated, gin.H{"data": message})
+	}
+}
+
+// POST /conversations/:id/read
+// Mark a conversation as read up to a message (default: the latest)
+func MarkConversationRead(c *gin.Context) {
+	var input models.MarkReadInput
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversation, ok := loadConversation(c)
+	if !ok {
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	err := conversation.MarkRead(user.ID, input.MessageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark conversation read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation marked as read"})
+}
+
+// loadConversation fetches the conversation named by the :id param if the
+// current user is a member, writing an error response and returning false
+// otherwise
+func loadConversation(c *gin.Context) (*models.Conversation, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation id"})
+		return nil, false
+	}
+
+	conversation, err := models.GetConversation(uint(id))
+	if err == nil && !conversation.HasMember(middleware.CurrentUser(c).ID) {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation"})
+		return nil, false
+	}
+	return conversation, true
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"io"
+	"time"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+// How often an idle stream gets a keep-alive, so proxies don't time it out
+const eventHeartbeatInterval = 25 * time.Second
+
+// GET /events
+// Stream the current user's messages, order updates and notifications as
+// server-sent events. The stream ends at the first heartbeat after its
+// access token expires or its session ends.
+func StreamEvents(notifications *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := middleware.CurrentUser(c)
+		sub := notifications.Subscribe(user.ID)
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(eventHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event, ok := <-sub.Events():
+				if !ok {
+					// Dropped by the hub; the client will reconnect
+					return false
+				}
+				c.SSEvent(event.Type, event.Data)
+				return true
+			case <-heartbeat.C:
+				// The stream outlives the checks RequireAuth made when it
+				// opened, so end it once logout, a ban or expiry would have
+				// rejected a new request
+				if !middleware.StillAuthorized(c) {
+					return false
+				}
+				c.SSEvent("ping", "")
+				return true
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET /notifications
+// Get the current user's notification inbox, newest first, with their
+// unread count for the app badge
+func FindNotifications(c *gin.Context) {
+	var filter models.NotificationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	notifications, next, err := models.FindNotifications(user.ID, filter)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	unread, err := models.CountUnreadNotifications(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread notifications"})
+		return
+	}
+
+	var nextCursor *string
+	if next != "" {
+		nextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notifications, "next_cursor": nextCursor, "unread_count": unread})
+}
+
+// GET /notifications/unread
+// Get the number of unread notifications, for the app badge
+func CountUnreadNotifications(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	unread, err := models.CountUnreadNotifications(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+// POST /notifications/:id/read
+// Mark one notification read
+func MarkNotificationRead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification id"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	err = models.MarkNotificationRead(user.ID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification read"})
+		return
+	}
+
+	respondUnreadNotifications(c, user.ID, "Notification marked as read")
+}
+
+// POST /notifications/read
+// Mark every notification read
+func MarkAllNotificationsRead(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if err := models.MarkAllNotificationsRead(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications read"})
+		return
+	}
+
+	respondUnreadNotifications(c, user.ID, "Notifications marked as read")
+}
+
+// respondUnreadNotifications confirms a change to the inbox along with the
+// new unread count, so clients can update their badge
+func respondUnreadNotifications(c *gin.Context, userID uint, message string) {
+	unread, err := models.CountUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "unread_count": unread})
+}
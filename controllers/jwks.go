@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/cuappdev/hustle-backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /.well-known/jwks.json
+// Get the public keys our JWTs can be verified with
+func JWKS(keys *auth.KeyRing) gin.HandlerFunc {
+	set := keys.JWKS()
+	return func(c *gin.Context) {
+		// Short enough that verifiers pick up a rotation before the old key is dropped
+		c.Header("Cache-Control", "public, max-age=900")
+		c.JSON(http.StatusOK, set)
+	}
+}
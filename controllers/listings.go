@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET /listings
+// Get all listings, optionally filtered by owner_id and category
+func FindListings(c *gin.Context) {
+	var filter models.ListingFilter
+	if ownerID := c.Query("owner_id"); ownerID != "" {
+		id, err := strconv.ParseUint(ownerID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid owner_id"})
+			return
+		}
+		filter.OwnerID = uint(id)
+	}
+	filter.Category = c.Query("category")
+
+	listings, err := models.FindListings(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": listings})
+}
+
+// GET /listings/search
+// Full-text search listings with optional filters, sorting and keyset pagination
+func SearchListings(c *gin.Context) {
+	var input models.ListingSearchInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, next, err := models.SearchListings(input)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search listings"})
+		return
+	}
+
+	var nextCursor *string
+	if next != "" {
+		nextCursor = &next
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results, "next_cursor": nextCursor})
+}
+
+// GET /listings/:id
+// Get a single listing
+func FindListing(c *gin.Context) {
+	listing, ok := loadListing(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": listing})
+}
+
+// POST /listings
+// Create a listing owned by the current user
+func CreateListing(c *gin.Context) {
+	var input models.CreateListingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listing := models.Listing{
+		OwnerID:      middleware.CurrentUser(c).ID,
+		Title:        input.Title,
+		Description:  input.Description,
+		Category:     input.Category,
+		PriceCents:   input.PriceCents,
+		PricingUnit:  input.PricingUnit,
+		Availability: input.Availability,
+	}
+	if err := models.DB.Create(&listing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create listing"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": listing})
+}
+
+// PATCH /listings/:id
+// Update a listing owned by the current user
+func UpdateListing(c *gin.Context) {
+	var input models.UpdateListingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listing, ok := loadOwnedListing(c)
+	if !ok {
+		return
+	}
+
+	if err := listing.Apply(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update listing"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": listing})
+}
+
+// DELETE /listings/:id
+// Soft delete a listing owned by the current user
+func DeleteListing(c *gin.Context) {
+	listing, ok := loadOwnedListing(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DB.Delete(listing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete listing"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Listing deleted successfully"})
+}
+
+// loadListing fetches the listing named by the :id param, writing an error
+// response and returning false if it can't
+func loadListing(c *gin.Context) (*models.Listing, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing id"})
+		return nil, false
+	}
+
+	listing, err := models.GetListing(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Listing not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listing"})
+		return nil, false
+	}
+	return listing, true
+}
+
+// loadOwnedListing is loadListing restricted to the current user's listings
+func loadOwnedListing(c *gin.Context) (*models.Listing, bool) {
+	listing, ok := loadListing(c)
+	if !ok {
+		return nil, false
+	}
+	if listing.OwnerID != middleware.CurrentUser(c).ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not own this listing"})
+		return nil, false
+	}
+	return listing, true
+}
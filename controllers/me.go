@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /me
+// Get the current user's own profile
+func FindMe(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+// PATCH /me
+// Update the current user's profile
+func UpdateMe(c *gin.Context) {
+	var input models.UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if err := user.ApplyProfile(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+// DELETE /me
+// Delete the current user's account, signing out every device
+func DeleteMe(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	err := user.Delete()
+	if errors.Is(err, models.ErrOpenOrders) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Finish or cancel your open orders before deleting your account"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"crypto/rand"
 	"net/http"
 	"github.com/gin-gonic/gin"
+	"github.com/cuappdev/hustle-backend/middleware"
 	"github.com/cuappdev/hustle-backend/models"
 	"github.com/cuappdev/hustle-backend/services"
 )
@@ -22,10 +24,15 @@ func RegisterFCMToken(c *gin.Context) {
         return
     }
     
-    // Get user ID from auth middleware
-    userID := c.GetUint("userID")
+    // Get user from auth middleware
+    user := middleware.CurrentUser(c)
     
-    err := models.SaveOrUpdateToken(userID, input.Token, input.Platform)
+    var sessionID *uint
+    if session := middleware.CurrentSession(c); session != nil {
+        sessionID = &session.ID
+    }
+    
+    err := models.SaveOrUpdateToken(user.ID, sessionID, input.Token, input.Platform)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register token"})
         return
@@ -36,7 +43,7 @@ func RegisterFCMToken(c *gin.Context) {
 
 
 // DELETE /fcm/delete
-// Delete an fcm token
+// Delete one of the current user's fcm tokens
 func DeleteFCMToken(c *gin.Context) {
     var input struct {
         Token string `json:"token" binding:"required"`
@@ -46,7 +53,9 @@ func DeleteFCMToken(c *gin.Context) {
         return
     }
     
-    err := models.DeleteToken(input.Token)
+    // Only the owner may unregister a device
+    user := middleware.CurrentUser(c)
+    err := models.DeleteUserToken(user.ID, input.Token)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete token"})
         return
@@ -57,47 +66,52 @@ func DeleteFCMToken(c *gin.Context) {
 
 // POST /fcm/test
 // Send a test notification to the user
-func SendTestNotification(c *gin.Context) {
-    userID := c.GetUint("userID")
+func SendTestNotification(notifications *services.NotificationService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        user := middleware.CurrentUser(c)
     
-    payload := services.NotificationPayload{
-        Title: "Test Notification",
-        Body:  "This is a test notification",
-        Data:  map[string]string{"type": "test"},
-    }
+        payload := services.NotificationPayload{
+            Title: "Test Notification",
+            Body:  "This is a test notification",
+            Data:  map[string]string{"type": "test"},
+        }
     
-    err := services.SendToUser(userID, payload)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
-    }
+        // Every test is a new event
+        err := notifications.SendToUser(user.ID, "test:"+rand.Text(), payload)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue notification"})
+            return
+        }
     
-    c.JSON(http.StatusOK, gin.H{"message": "Notification sent"})
+        c.JSON(http.StatusAccepted, gin.H{"message": "Notification queued"})
+    }
 }
 
 // Backend only (not exposed to clients)
 // Send a notification to a specific token
-func SendNotificationToToken(c *gin.Context) {
-	var input struct {
-		Token   string `json:"token" binding:"required"`
-		Title   string `json:"title" binding:"required"`
-		Body    string `json:"body" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+func SendNotificationToToken(notifications *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Token   string `json:"token" binding:"required"`
+			Title   string `json:"title" binding:"required"`
+			Body    string `json:"body" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	payload := services.NotificationPayload{
-		Title: input.Title,
-		Body:  input.Body,
-	}
+		payload := services.NotificationPayload{
+			Title: input.Title,
+			Body:  input.Body,
+		}
 
-	err := services.SendToToken(input.Token, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		err := notifications.SendToToken(c.Request.Context(), input.Token, payload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Notification sent"})
-}
\ No newline at end of file
+		c.JSON(http.StatusOK, gin.H{"message": "Notification sent"})
+	}
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/cuappdev/hustle-backend/realtime"
+	"github.com/cuappdev/hustle-backend/services"
+)
+
+func TestSendTestNotificationQueuesANotification(t *testing.T) {
+	store := services.NewFakeNotificationStore()
+	notifications := services.NewNotificationService(store, services.NewFakePusher(), realtime.NewHub(nil))
+	user := testUser()
+
+	c, w := testContext(http.MethodPost, "/api/fcm/test", nil, &user)
+	SendTestNotification(notifications)(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	queued := store.Enqueued()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d notifications, want 1", len(queued))
+	}
+	n := queued[0]
+	if n.UserID != user.ID || n.Title != "Test Notification" || !n.Push || !n.InApp {
+		t.Errorf("queued %+v, want a test push and inbox entry for the user", n)
+	}
+	if !strings.HasPrefix(n.IdempotencyKey, "test:") {
+		t.Errorf("key = %q, want a test: key", n.IdempotencyKey)
+	}
+}
+
+func TestNotifyOrderStatusRespectsOrderPreferences(t *testing.T) {
+	store := services.NewFakeNotificationStore()
+	notifications := services.NewNotificationService(store, services.NewFakePusher(), realtime.NewHub(nil))
+	order := &models.Order{ID: 5, BuyerID: 1, SellerID: 2}
+	transition := &models.OrderTransition{ID: 9, OrderID: order.ID, ToStatus: models.OrderAccepted}
+
+	if err := notifyOrderStatus(notifications, order, order.BuyerID)(nil, transition); err != nil {
+		t.Fatal(err)
+	}
+	queued := store.Enqueued()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d notifications, want 1", len(queued))
+	}
+	n := queued[0]
+	if n.UserID != order.BuyerID || n.Category != models.CategoryOrders || n.IdempotencyKey != "order_transition:9" {
+		t.Errorf("queued %+v, want the buyer's order update", n)
+	}
+	if n.Body != orderStatusMessages[models.OrderAccepted] || n.Data["status"] != models.OrderAccepted {
+		t.Errorf("queued %q with data %v, want the accepted message", n.Body, n.Data)
+	}
+
+	// Nothing is queued once order notifications are turned off
+	store.Preferences[models.CategoryOrders] = models.NotificationPreference{}
+	if err := notifyOrderStatus(notifications, order, order.SellerID)(nil, &models.OrderTransition{ID: 10, ToStatus: models.OrderInProgress}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.Enqueued()); n != 1 {
+		t.Errorf("queued %d more notifications, want none", n-1)
+	}
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/cuappdev/hustle-backend/realtime"
+	"github.com/cuappdev/hustle-backend/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Notification bodies sent to the other party when an order changes status
+var orderStatusMessages = map[string]string{
+	models.OrderRequested:  "You have a new order request",
+	models.OrderAccepted:   "Your order was accepted",
+	models.OrderDeclined:   "Your order was declined",
+	models.OrderInProgress: "Work on your order has started",
+	models.OrderCompleted:  "Your order was completed",
+	models.OrderCancelled:  "An order was cancelled",
+	models.OrderDisputed:   "An order was disputed",
+}
+
+// GET /orders
+// Get the current user's orders, optionally filtered by role (buyer/seller) and status
+func FindOrders(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	orders, err := models.FindUserOrders(user.ID, c.Query("role"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": orders})
+}
+
+// GET /orders/:id
+// Get an order the current user is party to
+func FindOrder(c *gin.Context) {
+	order, ok := loadOrder(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": order})
+}
+
+// GET /orders/:id/transitions
+// Get the status history of an order
+func FindOrderTransitions(c *gin.Context) {
+	order, ok := loadOrder(c)
+	if !ok {
+		return
+	}
+
+	transitions, err := order.Transitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transitions})
+}
+
+// POST /orders
+// Request a service from a listing's owner
+func CreateOrder(notifications *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.CreateOrderInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user := middleware.CurrentUser(c)
+
+		listing, err := models.GetListing(input.ListingID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Listing not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listing"})
+			return
+		}
+		if listing.OwnerID == user.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot order your own listing"})
+			return
+		}
+
+		order := models.Order{
+			ListingID:   listing.ID,
+			BuyerID:     user.ID,
+			SellerID:    listing.OwnerID,
+			Description: input.Description,
+			PriceCents:  listing.PriceCents,
+		}
+		if input.PriceCents != nil {
+			order.PriceCents = *input.PriceCents
+		}
+		if err := models.CreateOrder(&order, notifyOrderStatus(notifications, &order, order.SellerID)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			return
+		}
+
+		publishOrder(notifications, &order)
+
+		c.JSON(http.StatusCreated, gin.H{"data": order})
+	}
+}
+
+// POST /orders/:id/{accept,decline,start,complete,cancel,dispute}
+// Move an order to the given status
+func TransitionOrder(notifications *services.NotificationService, to string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := loadOrder(c)
+		if !ok {
+			return
+		}
+
+		user := middleware.CurrentUser(c)
+		recipient := order.BuyerID
+		if user.ID == order.BuyerID {
+			recipient = order.SellerID
+		}
+		err := order.Transition(user.ID, to, notifyOrderStatus(notifications, order, recipient))
+		switch {
+		case errors.Is(err, models.ErrIllegalTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot move order from %s to %s", order.Status, to)})
+			return
+		case errors.Is(err, models.ErrNotOrderParty):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot make this change to the order"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+			return
+		}
+
+		publishOrder(notifications, order)
+
+		c.JSON(http.StatusOK, gin.H{"data": order})
+	}
+}
+
+// publishOrder streams the order's current state to both parties
+func publishOrder(notifications *services.NotificationService, order *models.Order) {
+	notifications.Publish(order.BuyerID, realtime.EventOrder, order)
+	notifications.Publish(order.SellerID, realtime.EventOrder, order)
+}
+
+// notifyOrderStatus queues a push of the order's new status to recipientID
+func notifyOrderStatus(notifications *services.NotificationService, order *models.Order, recipientID uint) models.NotifyOrder {
+	return func(tx *gorm.DB, transition *models.OrderTransition) error {
+		payload := services.NotificationPayload{
+			Category: models.CategoryOrders,
+			Title:    "Order update",
+			Body:     orderStatusMessages[transition.ToStatus],
+			Data: map[string]string{
+				"type":     "order_status",
+				"order_id": strconv.FormatUint(uint64(order.ID), 10),
+				"status":   transition.ToStatus,
+			},
+		}
+		key := fmt.Sprintf("order_transition:%d", transition.ID)
+		return notifications.Notify(tx, recipientID, key, payload)
+	}
+}
+
+// loadOrder fetches the order named by the :id param if the current user is
+// party to it, writing an error response and returning false otherwise
+func loadOrder(c *gin.Context) (*models.Order, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order id"})
+		return nil, false
+	}
+
+	order, err := models.GetOrder(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return nil, false
+	}
+	if !order.HasParty(middleware.CurrentUser(c).ID) {
+		// Don't reveal that the order exists
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+	return order, true
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /me/notification-preferences
+// Get the current user's notification channels for every category and their
+// quiet hours
+func FindNotificationPreferences(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	preferences, err := models.FindNotificationPreferences(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+	quiet, err := models.GetQuietHours(models.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiet hours"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"categories": preferences, "quiet_hours": quiet}})
+}
+
+// PUT /me/notification-preferences/:category
+// Choose the channels one category of notification is sent on
+func UpdateNotificationPreference(c *gin.Context) {
+	category := c.Param("category")
+	if !slices.Contains(models.NotificationCategories, category) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification category not found"})
+		return
+	}
+
+	var input models.UpdateNotificationPreferenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	preference, err := models.SaveNotificationPreference(user.ID, category, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save notification preference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preference})
+}
+
+// PUT /me/quiet-hours
+// Set the daily window during which push notifications are held
+func UpdateQuietHours(c *gin.Context) {
+	var input models.UpdateQuietHoursInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	quiet, err := models.SaveQuietHours(user.ID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quiet hours"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quiet})
+}
+
+// DELETE /me/quiet-hours
+// Turn off quiet hours
+func DeleteQuietHours(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if err := models.DeleteQuietHours(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quiet hours"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quiet hours turned off"})
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET /users/:id/profile
+// Get a user's public profile, including their seller profile and portfolio
+func FindUserProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	profile, err := models.GetProfile(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+// PUT /me/seller-profile
+// Create or replace the current user's seller profile
+func UpdateSellerProfile(c *gin.Context) {
+	var input models.UpdateSellerProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	profile, err := models.SaveSellerProfile(user.ID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save seller profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+// POST /me/portfolio
+// Add an item to the current user's portfolio
+func CreatePortfolioItem(c *gin.Context) {
+	var input models.CreatePortfolioItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	item, err := models.CreatePortfolioItem(user.ID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create portfolio item"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": item})
+}
+
+// DELETE /me/portfolio/:id
+// Remove an item from the current user's portfolio
+func DeletePortfolioItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio item id"})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	err = models.DeletePortfolioItem(user.ID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio item not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Portfolio item deleted"})
+}
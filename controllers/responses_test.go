@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	firebaseauth "firebase.google.com/go/v4/auth"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cuappdev/hustle-backend/auth"
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/option"
+)
+
+// Keys that must never be sent to a client inside a user or session
+var secretKeys = []string{"refresh_token", "refresh_token_hash", "token_hash", "Refresh_Token", "firebase_name", "family_id", "deleted_at"}
+
+// Keys that only the user themselves and admins may see
+var privateKeys = []string{"firebase_uid", "email", "email_verified", "role", "banned_at"}
+
+// Values the database holds that must never be sent to a client
+const (
+	secretHash         = "refresh-token-hash-secret"
+	secretFamily       = "session-family-secret"
+	secretFirebaseName = "firebase-name-secret"
+)
+
+// Values only the user themselves and admins may see
+const secretUID = "firebase-uid-secret"
+
+func testUser() models.User {
+	year := 2027
+	return models.User{
+		ID:             42,
+		Firebase_UID:   secretUID,
+		FirstName:      "Ada",
+		LastName:       "Lovelace",
+		Email:          "ada@cornell.edu",
+		EmailVerified:  true,
+		Bio:            "Tutor",
+		GraduationYear: &year,
+		FirebaseName:   secretFirebaseName,
+		Role:           models.RoleUser,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+// keysIn returns every object key anywhere in a decoded JSON value
+func keysIn(v interface{}) map[string]bool {
+	keys := map[string]bool{}
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				keys[k] = true
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	return keys
+}
+
+// decodeResponse checks the handler answered with status and returns its
+// body decoded generically
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, status int) map[string]interface{} {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+// assertNoSecrets fails if v, or its JSON, has any secret key or value
+func assertNoSecrets(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	keys := keysIn(v)
+	for _, k := range secretKeys {
+		if keys[k] {
+			t.Errorf("%s: response contains %q", name, k)
+		}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{secretHash, secretFamily, secretFirebaseName} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("%s: response contains %q", name, secret)
+		}
+	}
+}
+
+// assertPublic fails if v, or its JSON, has anything only the user may see
+func assertPublic(t *testing.T, name string, v interface{}, user models.User) {
+	t.Helper()
+	assertNoSecrets(t, name, v)
+	keys := keysIn(v)
+	for _, k := range privateKeys {
+		if keys[k] {
+			t.Errorf("%s: response contains %q", name, k)
+		}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), user.Firebase_UID) || strings.Contains(string(raw), user.Email) {
+		t.Errorf("%s: response contains the user's Firebase UID or email", name)
+	}
+}
+
+func TestFindUsersHasNoSecrets(t *testing.T) {
+	mock := mockDB(t)
+	user := testUser()
+	admin := models.User{ID: 1, Role: models.RoleAdmin}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).WillReturnRows(userRows(user))
+
+	c, w := testContext(http.MethodGet, "/api/admin/users", nil, &admin)
+	FindUsers(c)
+
+	response := decodeResponse(t, w, http.StatusOK)
+	assertNoSecrets(t, "GET /admin/users", response)
+	if users, _ := response["data"].([]interface{}); len(users) != 1 {
+		t.Fatalf("data = %v, want the user", response["data"])
+	}
+}
+
+func TestUpdateUserRoleHasNoSecrets(t *testing.T) {
+	mock := mockDB(t)
+	user := testUser()
+	admin := models.User{ID: 1, Role: models.RoleAdmin}
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).WillReturnRows(userRows(user))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "role"=`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c, w := testContext(http.MethodPatch, "/api/admin/users/42/role", strings.NewReader(`{"role": "moderator"}`), &admin)
+	c.Params = gin.Params{{Key: "id", Value: "42"}}
+	UpdateUserRole(c)
+
+	assertNoSecrets(t, "PATCH /admin/users/:id/role", decodeResponse(t, w, http.StatusOK))
+}
+
+func TestCreateUserHasNoSecrets(t *testing.T) {
+	mock := mockDB(t)
+	user := testUser()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(user.ID))
+	mock.ExpectCommit()
+
+	token := &firebaseauth.Token{UID: secretUID, Claims: map[string]interface{}{
+		"email":          user.Email,
+		"email_verified": true,
+		"name":           secretFirebaseName,
+	}}
+	c, w := testContext(http.MethodPost, "/api/users", strings.NewReader(`{"firstname": "Ada", "lastname": "Lovelace"}`), nil)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), middleware.FirebaseTokenKey, token))
+	CreateUser(auth.SignupPolicy{Domains: []string{"cornell.edu"}})(c)
+
+	assertNoSecrets(t, "POST /users", decodeResponse(t, w, http.StatusOK))
+}
+
+// firebaseIDToken returns an unsigned Firebase ID token for user, which the
+// auth client accepts when FIREBASE_AUTH_EMULATOR_HOST is set and the
+// emulator knows the user
+func firebaseIDToken(t *testing.T, projectID string, user models.User) string {
+	t.Helper()
+	now := time.Now().Unix()
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":            "https://securetoken.google.com/" + projectID,
+		"aud":            projectID,
+		"sub":            user.Firebase_UID,
+		"iat":            now,
+		"exp":            now + 3600,
+		"auth_time":      now,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.FirebaseName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	return fmt.Sprintf("%s.%s.", encode([]byte(`{"alg":"none","typ":"JWT"}`)), encode(claims))
+}
+
+func TestVerifyTokenUserHasNoSecrets(t *testing.T) {
+	// Stands in for the Auth emulator, which looks up the token's user
+	emulator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"users": [{"localId": %q}]}`, secretUID)
+	}))
+	defer emulator.Close()
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", strings.TrimPrefix(emulator.URL, "http://"))
+	t.Setenv("JWT_SIGNING_KEY_FILE", "")
+	t.Setenv("JWT_ALLOW_EPHEMERAL_KEY", "true")
+	mock := mockDB(t)
+	user := testUser()
+
+	ctx := context.Background()
+	app, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: "test"}, option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	authClient, err := app.Auth(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := auth.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.LoadKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE firebase_uid = \$1`).
+		WithArgs(secretUID, 1).
+		WillReturnRows(userRows(user))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users" .* ON CONFLICT \("firebase_uid"\) DO UPDATE .* RETURNING \*`).
+		WillReturnRows(userRows(user))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`UPDATE "sessions" SET "expires_at"=\$1,"refresh_token_hash"=\$2`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := fmt.Sprintf(`{"token": %q}`, firebaseIDToken(t, "test", user))
+	c, w := testContext(http.MethodPost, "/api/verify-token", strings.NewReader(body), nil)
+	VerifyToken(authClient, auth.NewJWTService(config, keys), auth.SignupPolicy{Domains: []string{"cornell.edu"}})(c)
+
+	response := decodeResponse(t, w, http.StatusOK)
+	// The refresh token at the top level is the client's own; only the
+	// embedded user is checked
+	if response["refresh_token"] == "" {
+		t.Error("verify-token: no refresh token issued")
+	}
+	if _, ok := response["user"].(map[string]interface{}); !ok {
+		t.Fatalf("user = %v, want the signed-in user", response["user"])
+	}
+	assertNoSecrets(t, "POST /verify-token user", response["user"])
+}
+
+func TestFindSessionsHasNoSecrets(t *testing.T) {
+	mock := mockDB(t)
+	user := testUser()
+
+	mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "refresh_token_hash", "user_agent", "expires_at"}).
+			AddRow(3, user.ID, secretFamily, secretHash, "test", time.Now().Add(time.Hour)))
+
+	c, w := testContext(http.MethodGet, "/api/sessions", nil, &user)
+	FindSessions(c)
+
+	response := decodeResponse(t, w, http.StatusOK)
+	assertNoSecrets(t, "GET /sessions", response)
+	if sessions, _ := response["data"].([]interface{}); len(sessions) != 1 {
+		t.Fatalf("data = %v, want the session", response["data"])
+	}
+}
+
+func TestFindUserProfileIsPublic(t *testing.T) {
+	mock := mockDB(t)
+	user := testUser()
+	viewer := models.User{ID: 1}
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).WillReturnRows(userRows(user))
+	mock.ExpectQuery(`SELECT \* FROM "seller_profiles" WHERE user_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "bio", "skills"}).AddRow(user.ID, "Calculus", `["math"]`))
+	mock.ExpectQuery(`SELECT \* FROM "portfolio_items" WHERE user_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "title"}).AddRow(1, user.ID, "Notes"))
+
+	c, w := testContext(http.MethodGet, "/api/users/42/profile", nil, &viewer)
+	c.Params = gin.Params{{Key: "id", Value: "42"}}
+	FindUserProfile(c)
+
+	assertPublic(t, "GET /users/:id/profile", decodeResponse(t, w, http.StatusOK), user)
+}
+
+func TestFindMeReturnsOnlyPrivateUser(t *testing.T) {
+	user := testUser()
+
+	c, w := testContext(http.MethodGet, "/api/me", nil, &user)
+	FindMe(c)
+
+	response := decodeResponse(t, w, http.StatusOK)
+	assertNoSecrets(t, "GET /me", response)
+	if !keysIn(response)["email"] {
+		t.Error("GET /me: the user's own email is missing")
+	}
+}
+
+func TestUpdateMeHasNoSecrets(t *testing.T) {
+	mock := mockDB(t)
+	user := testUser()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c, w := testContext(http.MethodPatch, "/api/me", strings.NewReader(`{"bio": "Math tutor"}`), &user)
+	UpdateMe(c)
+
+	assertNoSecrets(t, "PATCH /me", decodeResponse(t, w, http.StatusOK))
+}
+
+func TestPublicUserHidesPrivateFields(t *testing.T) {
+	user := testUser()
+	assertPublic(t, "public user", user.Public(), user)
+}
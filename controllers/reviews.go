@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/cuappdev/hustle-backend/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// POST /orders/:id/reviews
+// Review the other party to a completed order
+func CreateReview(notifications *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.CreateReviewInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order, ok := loadOrder(c)
+		if !ok {
+			return
+		}
+
+		user := middleware.CurrentUser(c)
+		review, err := models.CreateReview(order, user.ID, input, func(tx *gorm.DB, review *models.Review) error {
+			payload := services.NotificationPayload{
+				Category: models.CategoryReviews,
+				Title:    "New review",
+				Body:     fmt.Sprintf("%s left you a %d-star review", user.FirstName, review.Rating),
+				Data: map[string]string{
+					"type":      "review",
+					"order_id":  strconv.FormatUint(uint64(order.ID), 10),
+					"review_id": strconv.FormatUint(uint64(review.ID), 10),
+				},
+			}
+			return notifications.Notify(tx, review.RevieweeID, fmt.Sprintf("review:%d", review.ID), payload)
+		})
+		switch {
+		case errors.Is(err, models.ErrOrderNotCompleted):
+			c.JSON(http.StatusConflict, gin.H{"error": "Only completed orders can be reviewed"})
+			return
+		case errors.Is(err, models.ErrAlreadyReviewed):
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this order"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"data": review})
+	}
+}
+
+// GET /users/:id/reviews
+// Get the reviews a user has received along with their aggregate rating
+func FindUserReviews(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	reviews, err := models.FindUserReviews(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": reviews,
+		"rating": gin.H{
+			"average": user.RatingAverage,
+			"count":   user.RatingCount,
+		},
+	})
+}
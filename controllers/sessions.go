@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LogoutRequest optionally names the device's FCM token, for clients that
+// registered it before sessions existed or signed in with a Firebase token
+type LogoutRequest struct {
+	FCMToken string `json:"fcm_token"`
+}
+
+// POST /api/logout
+// End the current session and stop its notifications
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user := middleware.CurrentUser(c)
+	if req.FCMToken != "" {
+		if err := models.DeleteUserToken(user.ID, req.FCMToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete token"})
+			return
+		}
+	}
+
+	if session := middleware.CurrentSession(c); session != nil {
+		if err := session.Revoke(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// POST /api/logout-all
+// End every session of the current user and stop all their notifications
+func LogoutAll(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	if err := models.RevokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices"})
+}
+
+// GET /api/sessions
+// Get the current user's active sessions
+func FindSessions(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+
+	sessions, err := models.FindActiveSessions(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	if current := middleware.CurrentSession(c); current != nil {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current.ID
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// DELETE /api/sessions/:id
+// End one of the current user's sessions
+func DeleteSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session id"})
+		return
+	}
+
+	session, err := models.GetSession(uint(id))
+	if err == nil && session.UserID != middleware.CurrentUser(c).ID {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	if err := session.Revoke(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cuappdev/hustle-backend/middleware"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testContext returns a handler context for a request made by user, or by
+// nobody if user is nil, and the recorder its response is written to
+func testContext(method, target string, body io.Reader, user *models.User) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, body)
+	if body != nil {
+		c.Request.Header.Set("Content-Type", "application/json")
+	}
+	if user != nil {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), middleware.UserKey, user))
+	}
+	return c, w
+}
+
+// mockDB points models.DB at a sqlmock connection for the rest of the test
+// and checks every expected statement ran
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		models.DB = previous
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	})
+	return mock
+}
+
+// userRows returns the users as rows of the users table
+func userRows(users ...models.User) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{
+		"id", "firebase_uid", "first_name", "last_name", "email", "email_verified",
+		"bio", "graduation_year", "firebase_name", "role", "created_at", "updated_at",
+	})
+	for _, u := range users {
+		rows.AddRow(u.ID, u.Firebase_UID, u.FirstName, u.LastName, u.Email, u.EmailVerified,
+			u.Bio, u.GraduationYear, u.FirebaseName, u.Role, u.CreatedAt, u.UpdatedAt)
+	}
+	return rows
+}
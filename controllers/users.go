@@ -1,51 +1,168 @@
 package controllers
 
 import (
+"errors"
+"log"
 "net/http"
+"strconv"
 "strings"
+"time"
 
 "github.com/gin-gonic/gin"
+"gorm.io/gorm"
 "github.com/cuappdev/hustle-backend/models"
 "github.com/cuappdev/hustle-backend/middleware"
 	"github.com/cuappdev/hustle-backend/auth"
 	firebaseauth "firebase.google.com/go/v4/auth"
 )
 
-// GET /users
-// Get all users
+// GET /admin/users
+// Page through users, filtered by email domain, role, ban status and
+// creation date. The number of matching users is in X-Total-Count.
 func FindUsers(c *gin.Context) {
-	var users []models.User
-	models.DB.Find(&users)
+	var filter models.UserFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, total, next, err := models.FindUsers(filter)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"data": users})
+	var nextCursor *string
+	if next != "" {
+		nextCursor = &next
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, gin.H{"data": models.PrivateUsers(users), "next_cursor": nextCursor})
 }
 
-// POST /users
-// Create new user
-func CreateUser(c *gin.Context) {
-  // Validate input
-  var input models.CreateUserInput
+// PATCH /admin/users/:id/role
+// Change a user's role
+func UpdateUserRole(c *gin.Context) {
+  var input models.UpdateRoleInput
   if err := c.ShouldBindJSON(&input); err != nil {
     c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
     return
   }
-  
-  uid := middleware.UIDFrom(c)
-  if uid == "" {
-    c.JSON(http.StatusUnauthorized, gin.H{"error": "midding firebase uid"})
+
+  id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+    return
+  }
+
+  var user models.User
+  if err := models.DB.First(&user, id).Error; err != nil {
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+      c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+      return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+    return
+  }
+
+  // Keeps an admin from locking everyone out by demoting themselves
+  if user.ID == middleware.CurrentUser(c).ID {
+    c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change your own role"})
     return
   }
 
-  // Create user
-  user := models.User{
-    FirstName: input.FirstName, 
-    LastName: input.LastName, 
-    Email: input.Email,
-    Firebase_UID: uid,
+  if err := user.SetRole(input.Role); err != nil {
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+    return
   }
-  models.DB.Create(&user)
 
-  c.JSON(http.StatusOK, gin.H{"data": user})
+  c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+// POST /users
+// Create new user
+func CreateUser(policy auth.SignupPolicy) gin.HandlerFunc {
+  return func(c *gin.Context) {
+    // Validate input
+    var input models.CreateUserInput
+    if err := c.ShouldBindJSON(&input); err != nil {
+      c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+      return
+    }
+  
+    token := middleware.FirebaseToken(c)
+    if token == nil {
+      c.JSON(http.StatusUnauthorized, gin.H{"error": "missing firebase token"})
+      return
+    }
+    email, _ := token.Claims["email"].(string)
+    emailVerified, _ := token.Claims["email_verified"].(bool)
+    name, _ := token.Claims["name"].(string)
+    if !allowSignup(c, policy, email, emailVerified) {
+      return
+    }
+
+    // Create user
+    user := models.User{
+      FirstName: input.FirstName, 
+      LastName: input.LastName, 
+      Email: email,
+      EmailVerified: emailVerified,
+      FirebaseName: name,
+      Firebase_UID: token.UID,
+    }
+    if err := models.DB.Create(&user).Error; err != nil {
+      if errors.Is(err, gorm.ErrDuplicatedKey) {
+        c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+        return
+      }
+      c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+      return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+  }
+}
+
+// Error codes of sign-ups the SignupPolicy rejects
+const (
+	codeEmailNotVerified      = "email_not_verified"
+	codeEmailDomainNotAllowed = "email_domain_not_allowed"
+)
+
+// allowSignup checks whether a Firebase account may create a user: its email
+// must be verified and either in an allowed domain or on the allowlist.
+// Otherwise it responds 403 with an error code and returns false.
+func allowSignup(c *gin.Context, policy auth.SignupPolicy, email string, emailVerified bool) bool {
+	if email == "" || !emailVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Verify your email address before signing up",
+			"code":  codeEmailNotVerified,
+		})
+		return false
+	}
+	if policy.AllowsDomain(email) {
+		return true
+	}
+
+	allowlisted, err := models.IsEmailAllowlisted(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
+		return false
+	}
+	if !allowlisted {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Sign-up is limited to " + strings.Join(policy.Domains, ", ") + " email addresses",
+			"code":  codeEmailDomainNotAllowed,
+		})
+		return false
+	}
+	return true
 }
 
 // VerifyTokenRequest represents the request body for token verification
@@ -55,7 +172,7 @@ type VerifyTokenRequest struct {
 
 // POST /api/verify-token
 // Verify Firebase token and return custom JWT tokens
-func VerifyToken(firebaseAuthClient *firebaseauth.Client) gin.HandlerFunc {
+func VerifyToken(firebaseAuthClient *firebaseauth.Client, jwtService *auth.JWTService, policy auth.SignupPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req VerifyTokenRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -72,41 +189,33 @@ func VerifyToken(firebaseAuthClient *firebaseauth.Client) gin.HandlerFunc {
 
 		// Extract user data from Firebase token
 		claims := firebaseToken.Claims
-		firebaseUID := firebaseToken.UID
-		
-		// Get user info from Firebase token claims
-		email, _ := claims["email"].(string)
-		name, _ := claims["name"].(string)
-		
-		// Parse name into first and last name
-		nameParts := strings.Fields(name)
-		firstName := ""
-		lastName := ""
-		if len(nameParts) > 0 {
-			firstName = nameParts[0]
-		}
-		if len(nameParts) > 1 {
-			lastName = strings.Join(nameParts[1:], " ")
-		}
+		identity := models.FirebaseIdentity{UID: firebaseToken.UID}
+		identity.Email, _ = claims["email"].(string)
+		identity.EmailVerified, _ = claims["email_verified"].(bool)
+		identity.Name, _ = claims["name"].(string)
 
-		// Find or create user in database
-		user, err := models.FindOrCreateUser(firebaseUID, email, firstName, lastName)
-		if err != nil {
+		// Only new accounts are subject to the sign-up policy
+		if _, err := models.FindUserByFirebaseUID(identity.UID); errors.Is(err, gorm.ErrRecordNotFound) {
+			if !allowSignup(c, policy, identity.Email, identity.EmailVerified) {
+				return
+			}
+		} else if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create/find user"})
 			return
 		}
 
-		// Generate JWT tokens
-		jwtService := auth.NewJWTService()
-		tokenPair, err := jwtService.GenerateTokenPair(firebaseUID, email)
+		// Find or create user in database, syncing it with the token's claims
+		user, err := models.FindOrCreateUser(identity)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create/find user"})
 			return
 		}
 
-		// Update user's refresh token in database
-		if err := user.UpdateRefreshToken(tokenPair.RefreshToken); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update refresh token"})
+		// Start a session for this device and generate its JWT tokens
+		var tokenPair *auth.TokenPair
+		_, err = models.StartSession(user.ID, c.Request.UserAgent(), issueTokens(jwtService, user, &tokenPair))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 			return
 		}
 
@@ -115,17 +224,24 @@ func VerifyToken(firebaseAuthClient *firebaseauth.Client) gin.HandlerFunc {
 			"access_token":  tokenPair.AccessToken,
 			"refresh_token": tokenPair.RefreshToken,
 			"expires_in":    tokenPair.ExpiresIn,
-			"user": gin.H{
-				"id":         user.ID,
-				"firebase_uid": user.Firebase_UID,
-				"email":      user.Email,
-				"firstname":  user.FirstName,
-				"lastname":   user.LastName,
-			},
+			"user":          user.Private(),
 		})
 	}
 }
 
+// issueTokens generates a token pair for the user's session, keeping it in
+// *pair so the handler can return it once the session is saved
+func issueTokens(jwtService *auth.JWTService, user *models.User, pair **auth.TokenPair) models.IssueRefreshToken {
+	return func(sessionID uint) (string, time.Time, error) {
+		tokenPair, err := jwtService.GenerateTokenPair(user.Firebase_UID, user.Email, user.Role, sessionID)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		*pair = tokenPair
+		return tokenPair.RefreshToken, tokenPair.RefreshExpiresAt, nil
+	}
+}
+
 // RefreshTokenRequest represents the request body for token refresh
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
@@ -133,7 +249,7 @@ type RefreshTokenRequest struct {
 
 // POST /api/refresh-token
 // Refresh access token using refresh token
-func RefreshToken() gin.HandlerFunc {
+func RefreshToken(jwtService *auth.JWTService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req RefreshTokenRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -142,36 +258,37 @@ func RefreshToken() gin.HandlerFunc {
 		}
 
 		// Validate refresh token
-		jwtService := auth.NewJWTService()
-		userID, err := jwtService.ValidateRefreshToken(req.RefreshToken)
+		claims, err := jwtService.ValidateRefreshToken(req.RefreshToken)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 			return
 		}
 
-		// Find user in database
+		// Find the session and its user
+		session, err := models.GetSession(claims.SessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
 		var user models.User
-		if err := models.DB.Where("firebase_uid = ?", userID).First(&user).Error; err != nil {
+		if err := models.DB.First(&user, session.UserID).Error; err != nil || user.Firebase_UID != claims.Subject {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
 		}
 
-		// Verify refresh token matches stored token
-		if user.Refresh_Token != req.RefreshToken {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		// Rotate the session's refresh token
+		var tokenPair *auth.TokenPair
+		err = session.Rotate(req.RefreshToken, c.Request.UserAgent(), issueTokens(jwtService, &user, &tokenPair))
+		switch {
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			log.Printf("[WARN] Refresh token reuse detected, revoked session family (session: %d, user: %d)", session.ID, user.ID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected; please sign in again"})
 			return
-		}
-
-		// Generate new token pair
-		tokenPair, err := jwtService.GenerateTokenPair(user.Firebase_UID, user.Email)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		case errors.Is(err, models.ErrSessionRevoked), errors.Is(err, models.ErrSessionExpired):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended; please sign in again"})
 			return
-		}
-
-		// Update user's refresh token
-		if err := user.UpdateRefreshToken(tokenPair.RefreshToken); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update refresh token"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 			return
 		}
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"os"
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,9 @@ import (
   	"github.com/cuappdev/hustle-backend/controllers"
 	"github.com/cuappdev/hustle-backend/auth"
 	"github.com/cuappdev/hustle-backend/middleware"  
+	"github.com/cuappdev/hustle-backend/migrations"
+	"github.com/cuappdev/hustle-backend/realtime"
+	"github.com/cuappdev/hustle-backend/services"
 )
 
 func main() {
@@ -18,16 +22,47 @@ func main() {
 		log.Println("Error loading .env file.")
 	}
 
+	// Subcommands (e.g. `hustle-backend migrate up`) run and exit
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	log.Println("Starting hustle-backend...")
   	r := gin.Default()
 	log.Println("Connecting to database...")
 	// Connect to DB
 	if err := models.ConnectDatabase(); err != nil {
-		log.Printf("[FATAL] Database connection failed: %v", err)
+		log.Fatalf("[FATAL] Database connection failed: %v", err)
 	}
 
-	// Migrate fcm token table
-	models.DB.AutoMigrate(&models.FCMToken{})
+	// Deployed images migrate on start (see Dockerfile); replicas starting
+	// together take turns through the migration lock
+	if os.Getenv("MIGRATE_ON_START") == "true" {
+		applied, err := migrations.Up(models.DB)
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+
+	// Refuse to serve against an outdated schema
+	if err := migrations.RequireCurrent(models.DB); err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+
+	// Load JWT settings and signing/verification keys
+	jwtConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("[FATAL] JWT config invalid: %v", err)
+	}
+	keys, err := auth.LoadKeyRing()
+	if err != nil {
+		log.Fatalf("[FATAL] JWT key setup failed: %v", err)
+	}
+	jwtService := auth.NewJWTService(jwtConfig, keys)
+	signupPolicy := auth.SignupPolicyFromEnv()
 
 	// Initialize Firebase Auth SAFELY
 	serviceAccountPath := "service-account-key.json"
@@ -44,30 +79,104 @@ func main() {
 	if err := auth.InitFirebase(serviceAccountPath); err != nil {
 		log.Printf("[FATAL] Firebase Messaging init failed: %v", err)
 	}
-	
+
+	// Start receiving real-time events (from other replicas, if a backplane is configured)
+	if err := realtime.DefaultHub.Start(context.Background()); err != nil {
+		log.Printf("[FATAL] Event hub failed to start: %v", err)
+	}
+
+	// Push notifications through Firebase, sending queued ones in the background
+	notifications := services.NewNotificationService(services.NewDBNotificationStore(), services.NewFirebasePusher(auth.GetMessagingClient()), realtime.DefaultHub)
+	notifications.StartOutbox(context.Background(), services.DefaultOutboxConfig)
 
 	log.Println("Setting up routes...")
 	// Public routes
 	r.GET("/healthcheck", controllers.HealthCheck)
+	r.GET("/.well-known/jwks.json", controllers.JWKS(keys))
 	
 	// Auth routes (public)
 	api := r.Group("/api")
 	{
-		api.POST("/verify-token", controllers.VerifyToken(ac))
-		api.POST("/refresh-token", controllers.RefreshToken())
+		api.POST("/verify-token", controllers.VerifyToken(ac, jwtService, signupPolicy))
+		api.POST("/refresh-token", controllers.RefreshToken(jwtService))
+		// Creating a user needs only a Firebase identity, since the user row
+		// RequireAuth resolves does not exist yet
+		api.POST("/users", middleware.RequireFirebaseUser(ac), controllers.CreateUser(signupPolicy))
+		// Profiles are public so sellers can share them with anyone
+		api.GET("/users/:id/profile", controllers.FindUserProfile)
 	}
 
 	// Protected routes
 	authd := api.Group("")
-	authd.Use(middleware.RequireAuth(ac))
+	authd.Use(middleware.RequireAuth(ac, jwtService))
 	{
+		// Session routes
+		authd.POST("/logout", controllers.Logout)
+		authd.POST("/logout-all", controllers.LogoutAll)
+		authd.GET("/sessions", controllers.FindSessions)
+		authd.DELETE("/sessions/:id", controllers.DeleteSession)
+		// Own profile routes
+		authd.GET("/me", controllers.FindMe)
+		authd.PATCH("/me", controllers.UpdateMe)
+		authd.DELETE("/me", controllers.DeleteMe)
+		authd.PUT("/me/seller-profile", controllers.UpdateSellerProfile)
+		authd.POST("/me/portfolio", controllers.CreatePortfolioItem)
+		authd.DELETE("/me/portfolio/:id", controllers.DeletePortfolioItem)
+		authd.GET("/me/notification-preferences", controllers.FindNotificationPreferences)
+		authd.PUT("/me/notification-preferences/:category", controllers.UpdateNotificationPreference)
+		authd.PUT("/me/quiet-hours", controllers.UpdateQuietHours)
+		authd.DELETE("/me/quiet-hours", controllers.DeleteQuietHours)
 		// User routes
-		authd.GET("/users", controllers.FindUsers)
-		authd.POST("/users", controllers.CreateUser)
+		authd.GET("/users/:id/reviews", controllers.FindUserReviews)
+		// Listing routes
+		authd.GET("/listings", controllers.FindListings)
+		authd.GET("/listings/search", controllers.SearchListings)
+		authd.GET("/listings/:id", controllers.FindListing)
+		authd.POST("/listings", controllers.CreateListing)
+		authd.PATCH("/listings/:id", controllers.UpdateListing)
+		authd.DELETE("/listings/:id", controllers.DeleteListing)
+		// Order routes
+		authd.GET("/orders", controllers.FindOrders)
+		authd.GET("/orders/:id", controllers.FindOrder)
+		authd.GET("/orders/:id/transitions", controllers.FindOrderTransitions)
+		authd.POST("/orders", controllers.CreateOrder(notifications))
+		authd.POST("/orders/:id/accept", controllers.TransitionOrder(notifications, models.OrderAccepted))
+		authd.POST("/orders/:id/decline", controllers.TransitionOrder(notifications, models.OrderDeclined))
+		authd.POST("/orders/:id/start", controllers.TransitionOrder(notifications, models.OrderInProgress))
+		authd.POST("/orders/:id/complete", controllers.TransitionOrder(notifications, models.OrderCompleted))
+		authd.POST("/orders/:id/cancel", controllers.TransitionOrder(notifications, models.OrderCancelled))
+		authd.POST("/orders/:id/dispute", controllers.TransitionOrder(notifications, models.OrderDisputed))
+		authd.POST("/orders/:id/reviews", controllers.CreateReview(notifications))
+		// Messaging routes
+		authd.GET("/conversations", controllers.FindConversations)
+		authd.GET("/conversations/unread", controllers.CountUnreadMessages)
+		authd.POST("/conversations", controllers.StartConversation)
+		authd.GET("/conversations/:id/messages", controllers.FindMessages)
+		authd.POST("/conversations/:id/messages", controllers.SendMessage(notifications))
+		authd.POST("/conversations/:id/read", controllers.MarkConversationRead)
+		// Real-time event stream
+		authd.GET("/events", controllers.StreamEvents(notifications))
+		// Notification inbox routes
+		authd.GET("/notifications", controllers.FindNotifications)
+		authd.GET("/notifications/unread", controllers.CountUnreadNotifications)
+		authd.POST("/notifications/read", controllers.MarkAllNotificationsRead)
+		authd.POST("/notifications/:id/read", controllers.MarkNotificationRead)
 		// Notification routes
 		authd.POST("/fcm/register", controllers.RegisterFCMToken)
         authd.DELETE("/fcm/delete", controllers.DeleteFCMToken)
-        authd.POST("/fcm/test", controllers.SendTestNotification)
+        authd.POST("/fcm/test", controllers.SendTestNotification(notifications))
+	}
+
+	// Admin routes
+	admin := authd.Group("/admin")
+	admin.Use(middleware.RequireRole(models.RoleAdmin, models.RoleModerator))
+	{
+		admin.GET("/users", controllers.FindUsers)
+		admin.PATCH("/users/:id/role", middleware.RequireRole(models.RoleAdmin), controllers.UpdateUserRole)
+		admin.GET("/allowlist", controllers.FindAllowedEmails)
+		admin.POST("/allowlist", middleware.RequireRole(models.RoleAdmin), controllers.CreateAllowedEmail)
+		admin.DELETE("/allowlist/:id", middleware.RequireRole(models.RoleAdmin), controllers.DeleteAllowedEmail)
+		admin.GET("/metrics", gin.WrapH(expvar.Handler()))
 	}
 	log.Println("Server starting on :8080")
 
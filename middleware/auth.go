@@ -2,20 +2,32 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	firebaseauth "firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"github.com/cuappdev/hustle-backend/auth"
+	"github.com/cuappdev/hustle-backend/models"
 )
 
 type ctxKey string
 
 const UIDKey ctxKey = "uid"
+const UserKey ctxKey = "user"
+const SessionKey ctxKey = "session"
+const FirebaseTokenKey ctxKey = "firebase_token"
+const TokenExpiryKey ctxKey = "token_expiry"
 
-// RequireAuth validates either Firebase tokens or custom JWT tokens
-func RequireAuth(firebaseAuthClient *firebaseauth.Client) gin.HandlerFunc {
+// RequireAuth validates either Firebase tokens or custom JWT tokens and
+// resolves the matching models.User, available to handlers via CurrentUser.
+// Requests with a valid token but no user row yet are rejected with 403.
+func RequireAuth(firebaseAuthClient *firebaseauth.Client, jwtService *auth.JWTService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		const pref = "Bearer "
 		authz := c.GetHeader("Authorization")
@@ -26,12 +38,18 @@ func RequireAuth(firebaseAuthClient *firebaseauth.Client) gin.HandlerFunc {
 		token := strings.TrimPrefix(authz, pref)
 
 		// Try to validate as custom JWT token first
-		jwtService := auth.NewJWTService()
-		if claims, err := jwtService.ValidateToken(token); err == nil {
+		claims, err := jwtService.ValidateToken(token)
+		if err == nil {
 			// Custom JWT token is valid
-			ctx := context.WithValue(c.Request.Context(), UIDKey, claims.UserID)
-			c.Request = c.Request.WithContext(ctx)
-			c.Next()
+			var expiresAt time.Time
+			if claims.ExpiresAt != nil {
+				expiresAt = claims.ExpiresAt.Time
+			}
+			setCurrentUser(c, claims.UserID, claims.SessionID, expiresAt)
+			return
+		}
+		if errors.Is(err, auth.ErrWrongTokenType) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "refresh tokens cannot be used as bearer tokens"})
 			return
 		}
 
@@ -42,9 +60,61 @@ func RequireAuth(firebaseAuthClient *firebaseauth.Client) gin.HandlerFunc {
 			return
 		}
 
-		// Firebase token is valid
-		ctx := context.WithValue(c.Request.Context(), UIDKey, firebaseToken.UID)
-		c.Request = c.Request.WithContext(ctx)
+		// Firebase token is valid; it isn't tied to a session
+		setCurrentUser(c, firebaseToken.UID, 0, time.Unix(firebaseToken.Expires, 0))
+	}
+}
+
+// setCurrentUser loads the user for uid (and their session, if sessionID is
+// set) into the request context along with when the token expires, aborting
+// if the user has not been created yet or the session has ended.
+func setCurrentUser(c *gin.Context, uid string, sessionID uint, expiresAt time.Time) {
+	var user models.User
+	if err := models.DB.Where("firebase_uid = ?", uid).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "user not registered"})
+			return
+		}
+		log.Printf("[ERROR] Failed to load user (Firebase UID: %s): %v", uid, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+	if user.BannedAt != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), UIDKey, uid)
+	ctx = context.WithValue(ctx, UserKey, &user)
+	ctx = context.WithValue(ctx, TokenExpiryKey, expiresAt)
+
+	if sessionID != 0 {
+		session, err := models.GetSession(sessionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[ERROR] Failed to load session %d: %v", sessionID, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load session"})
+			return
+		}
+		if err != nil || session.UserID != user.ID || !session.Active() {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session has ended"})
+			return
+		}
+		ctx = context.WithValue(ctx, SessionKey, session)
+	}
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// RequireRole allows the request only if the current user has one of the
+// given roles. It must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil || !slices.Contains(roles, user.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -66,8 +136,9 @@ func RequireFirebaseUser(ac *firebaseauth.Client) gin.HandlerFunc {
 			return
 		}
 
-		// put uid into context for handlers
+		// put uid and token into context for handlers
 		ctx := context.WithValue(c.Request.Context(), UIDKey, tok.UID)
+		ctx = context.WithValue(ctx, FirebaseTokenKey, tok)
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
@@ -79,3 +150,78 @@ func UIDFrom(c *gin.Context) string {
 	if s, ok := v.(string); ok { return s }
 	return ""
 }
+
+// FirebaseToken returns the token verified by RequireFirebaseUser, or nil on
+// routes that are not behind it.
+func FirebaseToken(c *gin.Context) *firebaseauth.Token {
+	if t, ok := c.Request.Context().Value(FirebaseTokenKey).(*firebaseauth.Token); ok {
+		return t
+	}
+	return nil
+}
+
+// CurrentUser returns the user resolved by RequireAuth, or nil on routes
+// that are not behind it.
+func CurrentUser(c *gin.Context) *models.User {
+	if u, ok := c.Request.Context().Value(UserKey).(*models.User); ok {
+		return u
+	}
+	return nil
+}
+
+// TokenExpiry returns when the token used for the request expires, or the
+// zero time on routes that are not behind RequireAuth.
+func TokenExpiry(c *gin.Context) time.Time {
+	if t, ok := c.Request.Context().Value(TokenExpiryKey).(time.Time); ok {
+		return t
+	}
+	return time.Time{}
+}
+
+// StillAuthorized re-checks the credentials of a long-lived request, such
+// as an event stream, that RequireAuth let through when it began. It
+// reports false once the token has expired, the session has ended, or the
+// user has been banned or deleted.
+func StillAuthorized(c *gin.Context) bool {
+	if expiresAt := TokenExpiry(c); !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		return false
+	}
+
+	user := CurrentUser(c)
+	if user == nil {
+		return false
+	}
+	var current models.User
+	if err := models.DB.Select("id", "banned_at").First(&current, user.ID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[ERROR] Failed to reload user %d: %v", user.ID, err)
+		}
+		return false
+	}
+	if current.BannedAt != nil {
+		return false
+	}
+
+	if session := CurrentSession(c); session != nil {
+		current, err := models.GetSession(session.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("[ERROR] Failed to reload session %d: %v", session.ID, err)
+			}
+			return false
+		}
+		if !current.Active() {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrentSession returns the session of the access token used for the
+// request, or nil if it was authenticated with a Firebase ID token.
+func CurrentSession(c *gin.Context) *models.Session {
+	if s, ok := c.Request.Context().Value(SessionKey).(*models.Session); ok {
+		return s
+	}
+	return nil
+}
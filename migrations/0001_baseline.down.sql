@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS order_transitions;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS listings;
+DROP TABLE IF EXISTS fcm_tokens;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,118 @@
+-- Baseline schema. Written with IF NOT EXISTS so databases created by the
+-- old AutoMigrate setup adopt it. Columns added to tables that setup
+-- already had are added separately, since CREATE TABLE IF NOT EXISTS skips
+-- an existing table.
+
+CREATE TABLE IF NOT EXISTS users (
+    id             BIGSERIAL PRIMARY KEY,
+    firebase_uid   TEXT,
+    refresh_token  TEXT,
+    first_name     TEXT,
+    last_name      TEXT,
+    email          TEXT,
+    rating_average DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rating_count   BIGINT NOT NULL DEFAULT 0,
+    created_at     TEXT,
+    updated_at     TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_firebase_uid ON users (firebase_uid);
+-- Ratings are newer than the users table in production
+ALTER TABLE users ADD COLUMN IF NOT EXISTS rating_average DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS rating_count BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS fcm_tokens (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL CONSTRAINT fk_fcm_tokens_user REFERENCES users (id),
+    token      TEXT NOT NULL,
+    platform   TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_fcm_tokens_user_id ON fcm_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fcm_tokens_token ON fcm_tokens (token);
+
+CREATE TABLE IF NOT EXISTS listings (
+    id           BIGSERIAL PRIMARY KEY,
+    owner_id     BIGINT NOT NULL CONSTRAINT fk_listings_owner REFERENCES users (id),
+    title        TEXT NOT NULL,
+    description  TEXT,
+    category     TEXT NOT NULL,
+    price_cents  BIGINT NOT NULL,
+    pricing_unit TEXT NOT NULL,
+    availability TEXT,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ,
+    deleted_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_listings_owner_id ON listings (owner_id);
+CREATE INDEX IF NOT EXISTS idx_listings_category ON listings (category);
+CREATE INDEX IF NOT EXISTS idx_listings_deleted_at ON listings (deleted_at);
+
+-- Full-text search over title (weighted higher) and description
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_listings_search_vector ON listings USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id          BIGSERIAL PRIMARY KEY,
+    listing_id  BIGINT NOT NULL CONSTRAINT fk_orders_listing REFERENCES listings (id),
+    buyer_id    BIGINT NOT NULL CONSTRAINT fk_orders_buyer REFERENCES users (id),
+    seller_id   BIGINT NOT NULL CONSTRAINT fk_orders_seller REFERENCES users (id),
+    description TEXT,
+    price_cents BIGINT NOT NULL,
+    status      TEXT NOT NULL,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_orders_listing_id ON orders (listing_id);
+CREATE INDEX IF NOT EXISTS idx_orders_buyer_id ON orders (buyer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_seller_id ON orders (seller_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
+
+CREATE TABLE IF NOT EXISTS order_transitions (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    BIGINT NOT NULL CONSTRAINT fk_order_transitions_order REFERENCES orders (id),
+    from_status TEXT,
+    to_status   TEXT NOT NULL,
+    actor_id    BIGINT NOT NULL CONSTRAINT fk_order_transitions_actor REFERENCES users (id),
+    created_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_order_transitions_order_id ON order_transitions (order_id);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    BIGINT NOT NULL CONSTRAINT fk_reviews_order REFERENCES orders (id),
+    reviewer_id BIGINT NOT NULL CONSTRAINT fk_reviews_reviewer REFERENCES users (id),
+    reviewee_id BIGINT NOT NULL CONSTRAINT fk_reviews_reviewee REFERENCES users (id),
+    rating      BIGINT NOT NULL CONSTRAINT chk_reviews_rating CHECK (rating BETWEEN 1 AND 5),
+    body        TEXT,
+    created_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_order_reviewer ON reviews (order_id, reviewer_id);
+CREATE INDEX IF NOT EXISTS idx_reviews_reviewee_id ON reviews (reviewee_id);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id                  BIGSERIAL PRIMARY KEY,
+    user_a_id           BIGINT NOT NULL CONSTRAINT fk_conversations_user_a REFERENCES users (id),
+    user_b_id           BIGINT NOT NULL CONSTRAINT fk_conversations_user_b REFERENCES users (id),
+    user_a_last_read_id BIGINT NOT NULL DEFAULT 0,
+    user_b_last_read_id BIGINT NOT NULL DEFAULT 0,
+    last_message_at     TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ,
+    updated_at          TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_pair ON conversations (user_a_id, user_b_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_user_b_id ON conversations (user_b_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_last_message_at ON conversations (last_message_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id              BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL CONSTRAINT fk_messages_conversation REFERENCES conversations (id),
+    sender_id       BIGINT NOT NULL CONSTRAINT fk_messages_sender REFERENCES users (id),
+    body            TEXT NOT NULL,
+    created_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id);
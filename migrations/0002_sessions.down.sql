@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN refresh_token TEXT;
+DROP TABLE sessions;
@@ -0,0 +1,18 @@
+-- One row per signed-in device, replacing the single refresh token per user
+CREATE TABLE sessions (
+    id                 BIGSERIAL PRIMARY KEY,
+    user_id            BIGINT NOT NULL CONSTRAINT fk_sessions_user REFERENCES users (id),
+    family_id          TEXT NOT NULL,
+    refresh_token_hash TEXT NOT NULL,
+    user_agent         TEXT,
+    last_used_at       TIMESTAMPTZ,
+    expires_at         TIMESTAMPTZ,
+    revoked_at         TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ,
+    updated_at         TIMESTAMPTZ
+);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_family_id ON sessions (family_id);
+
+-- Existing refresh tokens have no session and stop working; clients sign in again
+ALTER TABLE users DROP COLUMN refresh_token;
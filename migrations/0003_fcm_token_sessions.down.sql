@@ -0,0 +1 @@
+ALTER TABLE fcm_tokens DROP COLUMN session_id;
@@ -0,0 +1,4 @@
+-- Tie each FCM token to the session that registered it so logging out a
+-- device also stops its notifications
+ALTER TABLE fcm_tokens ADD COLUMN session_id BIGINT CONSTRAINT fk_fcm_tokens_session REFERENCES sessions (id);
+CREATE INDEX idx_fcm_tokens_session_id ON fcm_tokens (session_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CONSTRAINT chk_users_role CHECK (role IN ('user', 'moderator', 'admin'));
+CREATE INDEX idx_users_role ON users (role);
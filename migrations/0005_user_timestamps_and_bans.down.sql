@@ -0,0 +1,11 @@
+ALTER TABLE users DROP COLUMN banned_at;
+
+DROP INDEX idx_users_created_at;
+ALTER TABLE users
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN created_at DROP DEFAULT,
+    ALTER COLUMN updated_at DROP NOT NULL,
+    ALTER COLUMN updated_at DROP DEFAULT;
+ALTER TABLE users
+    ALTER COLUMN created_at TYPE TEXT USING created_at::text,
+    ALTER COLUMN updated_at TYPE TEXT USING updated_at::text;
@@ -0,0 +1,15 @@
+-- created_at/updated_at were declared as strings, which gorm never fills in,
+-- so existing values are empty
+ALTER TABLE users
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING NULLIF(created_at, '')::timestamptz,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING NULLIF(updated_at, '')::timestamptz;
+UPDATE users SET created_at = now() WHERE created_at IS NULL;
+UPDATE users SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE users
+    ALTER COLUMN created_at SET DEFAULT now(),
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(),
+    ALTER COLUMN updated_at SET NOT NULL;
+CREATE INDEX idx_users_created_at ON users (created_at, id);
+
+ALTER TABLE users ADD COLUMN banned_at TIMESTAMPTZ;
@@ -0,0 +1,8 @@
+DROP INDEX idx_users_deleted_at;
+ALTER TABLE users
+    DROP COLUMN bio,
+    DROP COLUMN pronouns,
+    DROP COLUMN graduation_year,
+    DROP COLUMN major,
+    DROP COLUMN photo_url,
+    DROP COLUMN deleted_at;
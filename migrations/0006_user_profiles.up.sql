@@ -0,0 +1,8 @@
+ALTER TABLE users
+    ADD COLUMN bio             TEXT NOT NULL DEFAULT '',
+    ADD COLUMN pronouns        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN graduation_year INTEGER,
+    ADD COLUMN major           TEXT NOT NULL DEFAULT '',
+    ADD COLUMN photo_url       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN deleted_at      TIMESTAMPTZ;
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
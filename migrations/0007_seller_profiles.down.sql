@@ -0,0 +1,4 @@
+DROP TABLE portfolio_items;
+DROP TABLE seller_profiles;
+
+ALTER TABLE users DROP COLUMN email_verified;
@@ -0,0 +1,23 @@
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE seller_profiles (
+    user_id                BIGINT PRIMARY KEY CONSTRAINT fk_seller_profiles_user REFERENCES users (id),
+    bio                    TEXT NOT NULL DEFAULT '',
+    skills                 JSONB NOT NULL DEFAULT '[]',
+    response_count         BIGINT NOT NULL DEFAULT 0,
+    response_seconds_total BIGINT NOT NULL DEFAULT 0,
+    created_at             TIMESTAMPTZ,
+    updated_at             TIMESTAMPTZ
+);
+CREATE INDEX idx_seller_profiles_skills ON seller_profiles USING GIN (skills);
+
+CREATE TABLE portfolio_items (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT NOT NULL CONSTRAINT fk_portfolio_items_user REFERENCES users (id),
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    media_urls  JSONB NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+CREATE INDEX idx_portfolio_items_user_id ON portfolio_items (user_id);
@@ -0,0 +1,8 @@
+CREATE TABLE allowed_emails (
+    id          BIGSERIAL PRIMARY KEY,
+    email       TEXT NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    added_by_id BIGINT CONSTRAINT fk_allowed_emails_added_by REFERENCES users (id),
+    created_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_allowed_emails_email ON allowed_emails (email);
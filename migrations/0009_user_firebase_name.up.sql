@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN firebase_name TEXT NOT NULL DEFAULT '';
+-- Assume existing names came from Firebase so the next sign-in does not
+-- treat them as changed
+UPDATE users SET firebase_name = concat_ws(' ', NULLIF(first_name, ''), NULLIF(last_name, ''))
+WHERE deleted_at IS NULL;
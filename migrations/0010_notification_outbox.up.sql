@@ -0,0 +1,20 @@
+CREATE TABLE outbox_notifications (
+    id              BIGSERIAL PRIMARY KEY,
+    idempotency_key TEXT NOT NULL,
+    user_id         BIGINT NOT NULL CONSTRAINT fk_outbox_notifications_user REFERENCES users (id),
+    title           TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    data            JSONB NOT NULL DEFAULT '{}',
+    status          TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT chk_outbox_notifications_status CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT NOT NULL DEFAULT '',
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_outbox_notifications_idempotency_key ON outbox_notifications (idempotency_key);
+CREATE INDEX idx_outbox_notifications_user_id ON outbox_notifications (user_id);
+-- Workers only ever look for due pending notifications
+CREATE INDEX idx_outbox_notifications_due ON outbox_notifications (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE outbox_notifications DROP COLUMN pending_tokens;
@@ -0,0 +1,3 @@
+-- Devices a partly failed notification still has to reach; NULL means all of
+-- the user's devices
+ALTER TABLE outbox_notifications ADD COLUMN pending_tokens JSONB;
@@ -0,0 +1,14 @@
+CREATE TABLE notifications (
+    id              BIGSERIAL PRIMARY KEY,
+    idempotency_key TEXT NOT NULL,
+    user_id         BIGINT NOT NULL CONSTRAINT fk_notifications_user REFERENCES users (id),
+    title           TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    data            JSONB NOT NULL DEFAULT '{}',
+    read_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_notifications_idempotency_key ON notifications (idempotency_key);
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, id);
+-- Badge counts only look at unread notifications
+CREATE INDEX idx_notifications_unread ON notifications (user_id) WHERE read_at IS NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE outbox_notifications DROP COLUMN category, DROP COLUMN push, DROP COLUMN in_app;
+ALTER TABLE notifications DROP COLUMN category;
+DROP TABLE quiet_hours;
+DROP TABLE notification_preferences;
@@ -0,0 +1,27 @@
+CREATE TABLE notification_preferences (
+    user_id    BIGINT NOT NULL CONSTRAINT fk_notification_preferences_user REFERENCES users (id),
+    category   TEXT NOT NULL
+        CONSTRAINT chk_notification_preferences_category CHECK (category IN ('messages', 'orders', 'reviews', 'marketing')),
+    push       BOOLEAN NOT NULL,
+    in_app     BOOLEAN NOT NULL,
+    email      BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, category)
+);
+
+CREATE TABLE quiet_hours (
+    user_id    BIGINT PRIMARY KEY CONSTRAINT fk_quiet_hours_user REFERENCES users (id),
+    timezone   TEXT NOT NULL,
+    start_time TEXT NOT NULL,
+    end_time   TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+-- What a notification is about and which channels its user wants it on
+ALTER TABLE notifications ADD COLUMN category TEXT NOT NULL DEFAULT '';
+ALTER TABLE outbox_notifications
+    ADD COLUMN category TEXT NOT NULL DEFAULT '',
+    ADD COLUMN push BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN in_app BOOLEAN NOT NULL DEFAULT TRUE;
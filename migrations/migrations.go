@@ -0,0 +1,232 @@
+// Package migrations applies the versioned SQL files in this directory.
+//
+// Each migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Versions are applied in ascending order and
+// recorded in the schema_migrations table. Never edit a migration that has
+// been deployed; add a new one instead.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Arbitrary key for the advisory lock that keeps replicas from migrating at
+// the same time
+const lockKey = 7351209841
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+)`
+
+// schemaMigration is a row of the schema_migrations table
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status is a migration along with whether it has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns all migrations, oldest first
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("badly named migration file: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		sql, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Statuses returns every migration and when it was applied, oldest first
+func Statuses(db *gorm.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(createSchemaMigrations).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = Status{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied, oldest first
+func Pending(db *gorm.DB) ([]Migration, error) {
+	statuses, err := Statuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations in order, each in its own transaction,
+// and returns the ones it applied
+func Up(db *gorm.DB) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		ran := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			// Another replica may have applied it while we waited for the lock
+			var count int64
+			if err := tx.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil || count > 0 {
+				return err
+			}
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// Down rolls back the most recently applied migrations, up to steps of them,
+// and returns the ones it rolled back
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	statuses, err := Statuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(statuses) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		m := statuses[i].Migration
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("rollback of %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		rolledBack = append(rolledBack, m)
+	}
+	return rolledBack, nil
+}
+
+// RequireCurrent returns an error if any migration has not been applied
+func RequireCurrent(db *gorm.DB) error {
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind: %d pending migration(s), starting with %d_%s; run `migrate up`",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// lock takes a transaction-scoped advisory lock, released on commit/rollback
+func lock(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AllowedEmail lets an address outside the allowed email domains sign up,
+// e.g. a Cornell affiliate using a personal account
+type AllowedEmail struct {
+	ID        uint      `json:"id" gorm:"primary_key"`
+	Email     string    `json:"email" gorm:"uniqueIndex;not null"` // stored lowercase
+	Note      string    `json:"note"`
+	AddedByID *uint     `json:"added_by_id"`
+	CreatedAt time.Time `json:"created_at"`
+	AddedBy   User      `json:"-" gorm:"foreignKey:AddedByID"`
+}
+
+type CreateAllowedEmailInput struct {
+	Email string `json:"email" binding:"required,email,max=320"`
+	Note  string `json:"note" binding:"max=500"`
+}
+
+// FindAllowedEmails returns every allowlist entry, newest first
+func FindAllowedEmails() ([]AllowedEmail, error) {
+	var entries []AllowedEmail
+	err := DB.Order("id DESC").Find(&entries).Error
+	return entries, err
+}
+
+// CreateAllowedEmail adds an address to the allowlist. Returns
+// gorm.ErrDuplicatedKey if it is already there.
+func CreateAllowedEmail(input CreateAllowedEmailInput, addedByID uint) (*AllowedEmail, error) {
+	entry := AllowedEmail{
+		Email:     strings.ToLower(input.Email),
+		Note:      input.Note,
+		AddedByID: &addedByID,
+	}
+	if err := DB.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// DeleteAllowedEmail removes an allowlist entry; users it let in keep their
+// accounts. Returns gorm.ErrRecordNotFound if there is no such entry.
+func DeleteAllowedEmail(id uint) error {
+	result := DB.Delete(&AllowedEmail{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// IsEmailAllowlisted reports whether email has an allowlist entry
+func IsEmailAllowlisted(email string) (bool, error) {
+	var count int64
+	err := DB.Model(&AllowedEmail{}).Where("email = ?", strings.ToLower(email)).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotConversationMember = errors.New("user is not a member of this conversation")
+
+// Conversation is a direct message thread between two users. UserAID is
+// always the smaller ID so each pair of users has a single conversation.
+type Conversation struct {
+	ID              uint       `json:"id" gorm:"primary_key"`
+	UserAID         uint       `json:"user_a_id" gorm:"not null;uniqueIndex:idx_conversations_pair"`
+	UserBID         uint       `json:"user_b_id" gorm:"not null;uniqueIndex:idx_conversations_pair;index"`
+	UserALastReadID uint       `json:"-" gorm:"not null;default:0"`
+	UserBLastReadID uint       `json:"-" gorm:"not null;default:0"`
+	LastMessageAt   *time.Time `json:"last_message_at" gorm:"index"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	UserA           User       `json:"-" gorm:"foreignKey:UserAID"`
+	UserB           User       `json:"-" gorm:"foreignKey:UserBID"`
+}
+
+type Message struct {
+	ID             uint         `json:"id" gorm:"primary_key"`
+	ConversationID uint         `json:"conversation_id" gorm:"index;not null"`
+	SenderID       uint         `json:"sender_id" gorm:"not null"`
+	Body           string       `json:"body" gorm:"not null"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Conversation   Conversation `json:"-" gorm:"foreignKey:ConversationID"`
+	Sender         User         `json:"-" gorm:"foreignKey:SenderID"`
+}
+
+// ConversationSummary is a conversation as seen by one of its members
+type ConversationSummary struct {
+	Conversation
+	OtherUserID uint  `json:"other_user_id"`
+	UnreadCount int64 `json:"unread_count"`
+}
+
+type StartConversationInput struct {
+	RecipientID uint `json:"recipient_id" binding:"required"`
+}
+
+type SendMessageInput struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+type MarkReadInput struct {
+	MessageID uint `json:"message_id"` // defaults to the latest message
+}
+
+// FindOrCreateConversation returns the conversation between two users,
+// creating it if this is their first
+func FindOrCreateConversation(userID, otherID uint) (*Conversation, error) {
+	a, b := userID, otherID
+	if a > b {
+		a, b = b, a
+	}
+	conversation := Conversation{UserAID: a, UserBID: b}
+	err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&conversation).Error
+	if err != nil {
+		return nil, err
+	}
+	if conversation.ID == 0 {
+		// Already existed
+		err = DB.Where("user_a_id = ? AND user_b_id = ?", a, b).First(&conversation).Error
+	}
+	return &conversation, err
+}
+
+// GetConversation finds a conversation by ID
+func GetConversation(id uint) (*Conversation, error) {
+	var conversation Conversation
+	if err := DB.First(&conversation, id).Error; err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// HasMember reports whether the user is part of the conversation
+func (cv *Conversation) HasMember(userID uint) bool {
+	return cv.UserAID == userID || cv.UserBID == userID
+}
+
+// OtherMember returns the ID of the member who is not userID
+func (cv *Conversation) OtherMember(userID uint) uint {
+	if cv.UserAID == userID {
+		return cv.UserBID
+	}
+	return cv.UserAID
+}
+
+// lastReadColumn is the read-pointer column belonging to userID
+func (cv *Conversation) lastReadColumn(userID uint) string {
+	if cv.UserAID == userID {
+		return "user_a_last_read_id"
+	}
+	return "user_b_last_read_id"
+}
+
+// unreadCountSQL is a subquery counting messages in the current conversations
+// row that are from the other member and newer than the member's read
+// pointer. It takes the member's ID twice.
+const unreadCountSQL = `(SELECT COUNT(*) FROM messages m
+	WHERE m.conversation_id = conversations.id AND m.sender_id <> ?
+	AND m.id > CASE WHEN conversations.user_a_id = ? THEN conversations.user_a_last_read_id ELSE conversations.user_b_last_read_id END)`
+
+// FindUserConversations returns the user's conversations with their unread
+// counts, most recently active first
+func FindUserConversations(userID uint) ([]ConversationSummary, error) {
+	var summaries []ConversationSummary
+	err := DB.Model(&Conversation{}).
+		Select("conversations.*, CASE WHEN user_a_id = ? THEN user_b_id ELSE user_a_id END AS other_user_id, "+unreadCountSQL+" AS unread_count",
+			userID, userID, userID).
+		Where("user_a_id = ? OR user_b_id = ?", userID, userID).
+		Order("last_message_at DESC NULLS LAST, id DESC").
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+// CountUnreadMessages returns the number of unread messages across all of
+// the user's conversations
+func CountUnreadMessages(userID uint) (int64, error) {
+	counts := DB.Model(&Conversation{}).
+		Select(unreadCountSQL+" AS unread_count", userID, userID).
+		Where("user_a_id = ? OR user_b_id = ?", userID, userID)
+
+	var total int64
+	err := DB.Table("(?) AS counts", counts).Select("COALESCE(SUM(unread_count), 0)").Scan(&total).Error
+	return total, err
+}
+
+// messageKeyset pages through messages newest first
+var messageKeyset = Keyset{Name: "messages", ID: "id"}
+
+// Messages returns one page of the conversation's messages, newest first,
+// and the cursor for the next (older) page
+func (cv *Conversation) Messages(page PageInput) ([]Message, string, error) {
+	query := DB.Where("conversation_id = ?", cv.ID)
+	return Paginate(query, messageKeyset, page, func(m *Message) (string, uint) {
+		return "", m.ID
+	})
+}
+
+// NotifyMessage queues the notifications for a new message using the
+// transaction that stores it
+type NotifyMessage func(tx *gorm.DB, message *Message) error
+
+// SendMessage stores a message from senderID and marks the conversation as
+// read by the sender up to that message
+func (cv *Conversation) SendMessage(senderID uint, body string, notify NotifyMessage) (*Message, error) {
+	if !cv.HasMember(senderID) {
+		return nil, ErrNotConversationMember
+	}
+
+	message := Message{ConversationID: cv.ID, SenderID: senderID, Body: body}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordResponse(tx, cv.ID, senderID, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		err := tx.Model(cv).Updates(map[string]interface{}{
+			"last_message_at":           message.CreatedAt,
+			cv.lastReadColumn(senderID): message.ID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return notify(tx, &message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// MarkRead advances the user's read pointer to messageID, or to the latest
+// message when messageID is 0. The pointer never moves backwards. Returns
+// gorm.ErrRecordNotFound if the message is not in this conversation.
+func (cv *Conversation) MarkRead(userID, messageID uint) error {
+	if !cv.HasMember(userID) {
+		return ErrNotConversationMember
+	}
+
+	query := DB.Where("conversation_id = ?", cv.ID)
+	if messageID != 0 {
+		query = query.Where("id = ?", messageID)
+	}
+	var message Message
+	err := query.Order("id DESC").First(&message).Error
+	if messageID == 0 && errors.Is(err, gorm.ErrRecordNotFound) {
+		// Nothing to read yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	column := cv.lastReadColumn(userID)
+	return DB.Model(cv).
+		Where(column+" < ?", message.ID).
+		Update(column, message.ID).Error
+}
@@ -5,6 +5,7 @@ import "time"
 type FCMToken struct {
     ID        uint      `json:"id" gorm:"primary_key"`
     UserID    uint      `json:"user_id" gorm:"index;not null"`
+    SessionID *uint     `json:"-" gorm:"index"` // the session that registered it, if any
     Token     string    `json:"token" gorm:"uniqueIndex;not null"`
     Platform  string    `json:"platform"` // "android" or "ios"
     CreatedAt time.Time `json:"created_at"`
@@ -13,7 +14,7 @@ type FCMToken struct {
 }
 
 // Save or update a token
-func SaveOrUpdateToken(userID uint, token, platform string) error {
+func SaveOrUpdateToken(userID uint, sessionID *uint, token, platform string) error {
     var fcmToken FCMToken
     
     // Check if this exact token already exists
@@ -22,15 +23,17 @@ func SaveOrUpdateToken(userID uint, token, platform string) error {
     if result.Error != nil {
         // Create new token
         fcmToken = FCMToken{
-            UserID:   userID,
-            Token:    token,
-            Platform: platform,
+            UserID:    userID,
+            SessionID: sessionID,
+            Token:     token,
+            Platform:  platform,
         }
         return DB.Create(&fcmToken).Error
     }
     
-    // Token exists - just update user/platform if needed
-    return DB.Model(&fcmToken).Updates(FCMToken{UserID: userID, Platform: platform}).Error
+    // Token exists - just update user/session/platform if needed
+    return DB.Model(&fcmToken).Select("user_id", "session_id", "platform").
+        Updates(FCMToken{UserID: userID, SessionID: sessionID, Platform: platform}).Error
 }
 
 // retrieves all FCM tokens for a user
@@ -51,4 +54,9 @@ func GetUserTokens(userID uint) ([]string, error) {
 // removes an FCM token
 func DeleteToken(token string) error {
     return DB.Where("token = ?", token).Delete(&FCMToken{}).Error
+}
+
+// removes an FCM token if it belongs to the user
+func DeleteUserToken(userID uint, token string) error {
+    return DB.Where("user_id = ? AND token = ?", userID, token).Delete(&FCMToken{}).Error
 }
\ No newline at end of file
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Listing struct {
+	ID           uint           `json:"id" gorm:"primary_key"`
+	OwnerID      uint           `json:"owner_id" gorm:"index;not null"`
+	Title        string         `json:"title" gorm:"not null"`
+	Description  string         `json:"description"`
+	Category     string         `json:"category" gorm:"index;not null"`
+	PriceCents   int64          `json:"price_cents" gorm:"not null"`
+	PricingUnit  string         `json:"pricing_unit" gorm:"not null"` // "flat", "hourly" or "session"
+	Availability string         `json:"availability"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	Owner        User           `json:"-" gorm:"foreignKey:OwnerID"`
+}
+
+type CreateListingInput struct {
+	Title        string `json:"title" binding:"required,max=120"`
+	Description  string `json:"description" binding:"max=5000"`
+	Category     string `json:"category" binding:"required,oneof=tutoring design photography beauty tech moving music other"`
+	PriceCents   int64  `json:"price_cents" binding:"min=0"`
+	PricingUnit  string `json:"pricing_unit" binding:"required,oneof=flat hourly session"`
+	Availability string `json:"availability" binding:"max=500"`
+}
+
+// Fields left nil are not changed
+type UpdateListingInput struct {
+	Title        *string `json:"title" binding:"omitempty,min=1,max=120"`
+	Description  *string `json:"description" binding:"omitempty,max=5000"`
+	Category     *string `json:"category" binding:"omitempty,oneof=tutoring design photography beauty tech moving music other"`
+	PriceCents   *int64  `json:"price_cents" binding:"omitempty,min=0"`
+	PricingUnit  *string `json:"pricing_unit" binding:"omitempty,oneof=flat hourly session"`
+	Availability *string `json:"availability" binding:"omitempty,max=500"`
+}
+
+// ListingFilter narrows FindListings; zero values are ignored
+type ListingFilter struct {
+	OwnerID  uint
+	Category string
+}
+
+// FindListings returns non-deleted listings, newest first
+func FindListings(filter ListingFilter) ([]Listing, error) {
+	query := DB.Order("created_at DESC")
+	if filter.OwnerID != 0 {
+		query = query.Where("owner_id = ?", filter.OwnerID)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+
+	var listings []Listing
+	if err := query.Find(&listings).Error; err != nil {
+		return nil, err
+	}
+	return listings, nil
+}
+
+// GetListing finds a non-deleted listing by ID
+func GetListing(id uint) (*Listing, error) {
+	var listing Listing
+	if err := DB.First(&listing, id).Error; err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// Apply copies the set fields of input onto the listing and saves it
+func (l *Listing) Apply(input UpdateListingInput) error {
+	if input.Title != nil {
+		l.Title = *input.Title
+	}
+	if input.Description != nil {
+		l.Description = *input.Description
+	}
+	if input.Category != nil {
+		l.Category = *input.Category
+	}
+	if input.PriceCents != nil {
+		l.PriceCents = *input.PriceCents
+	}
+	if input.PricingUnit != nil {
+		l.PricingUnit = *input.PricingUnit
+	}
+	if input.Availability != nil {
+		l.Availability = *input.Availability
+	}
+	return DB.Save(l).Error
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"gorm.io/gorm/clause"
+)
+
+const defaultSearchPageSize = 20
+
+// Search sort orders
+const (
+	SortRelevance = "relevance"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortRating    = "rating"
+	SortRecent    = "recent"
+)
+
+// tsQuerySQL turns the user's query into a tsquery, accepting web search
+// syntax ("quoted phrases", -excluded, or)
+const tsQuerySQL = "websearch_to_tsquery('english', ?)"
+
+// searchSort is the keyset for one sort order: rows are ordered by expr
+// then listings.id, both ascending or both descending
+type searchSort struct {
+	expr      string
+	needsText bool // expr takes the search query as its only argument
+	asc       bool
+	parse     func(string) (interface{}, error)
+}
+
+var searchSorts = map[string]searchSort{
+	// Cast to float8 so the rank survives the round trip through the cursor exactly
+	SortRelevance: {expr: "ts_rank(listings.search_vector, " + tsQuerySQL + ")::float8", needsText: true, parse: parseFloat},
+	SortPriceAsc:  {expr: "listings.price_cents", asc: true, parse: parseInt},
+	SortPriceDesc: {expr: "listings.price_cents", parse: parseInt},
+	SortRating:    {expr: "users.rating_average", parse: parseFloat},
+	SortRecent:    {expr: "listings.created_at", parse: parseTime},
+}
+
+type ListingSearchInput struct {
+	Query     string   `form:"q" binding:"max=200"`
+	Category  string   `form:"category"`
+	MinPrice  *int64   `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice  *int64   `form:"max_price" binding:"omitempty,min=0"`
+	MinRating *float64 `form:"min_rating" binding:"omitempty,min=0,max=5"`
+	SellerID  uint     `form:"seller_id"`
+	Sort      string   `form:"sort" binding:"omitempty,oneof=relevance price_asc price_desc rating recent"` // defaults to relevance with a query, recent without
+	Cursor    string   `form:"cursor"`
+	Limit     int      `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// ListingSearchResult is a matching listing along with its seller's rating
+type ListingSearchResult struct {
+	Listing
+	SellerRating float64 `json:"seller_rating"`
+	SortValue    string  `json:"-"`
+}
+
+// SearchListings runs a full-text, faceted search over non-deleted
+// listings. It returns one page of results and the cursor for the next page,
+// which is empty on the last page.
+func SearchListings(input ListingSearchInput) ([]ListingSearchResult, string, error) {
+	sortName := input.Sort
+	if sortName == "" || (sortName == SortRelevance && input.Query == "") {
+		sortName = SortRecent
+		if input.Query != "" {
+			sortName = SortRelevance
+		}
+	}
+	sort := searchSorts[sortName]
+	limit := input.Limit
+	if limit == 0 {
+		limit = defaultSearchPageSize
+	}
+
+	var sortArgs []interface{}
+	if sort.needsText {
+		sortArgs = []interface{}{input.Query}
+	}
+
+	query := DB.Model(&Listing{}).
+		Joins("JOIN users ON users.id = listings.owner_id").
+		Select("listings.*, users.rating_average AS seller_rating, "+sort.expr+" AS sort_value", sortArgs...)
+
+	if input.Query != "" {
+		query = query.Where("listings.search_vector @@ "+tsQuerySQL, input.Query)
+	}
+	if input.Category != "" {
+		query = query.Where("listings.category = ?", input.Category)
+	}
+	if input.MinPrice != nil {
+		query = query.Where("listings.price_cents >= ?", *input.MinPrice)
+	}
+	if input.MaxPrice != nil {
+		query = query.Where("listings.price_cents <= ?", *input.MaxPrice)
+	}
+	if input.MinRating != nil {
+		query = query.Where("users.rating_average >= ?", *input.MinRating)
+	}
+	if input.SellerID != 0 {
+		query = query.Where("listings.owner_id = ?", input.SellerID)
+	}
+
+	cmp, dir := "<", "DESC"
+	if sort.asc {
+		cmp, dir = ">", "ASC"
+	}
+	if input.Cursor != "" {
+		value, id, err := decodeCursor(input.Cursor, sortName, sort.parse)
+		if err != nil {
+			return nil, "", err
+		}
+		args := append(append([]interface{}{}, sortArgs...), value, id)
+		query = query.Where("("+sort.expr+", listings.id) "+cmp+" (?, ?)", args...)
+	}
+
+	query = query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                sort.expr + " " + dir + ", listings.id " + dir,
+		Vars:               sortArgs,
+		WithoutParentheses: true,
+	}}).Limit(limit)
+
+	var results []ListingSearchResult
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(results) == limit {
+		last := results[len(results)-1]
+		next = encodeCursor(sortName, last.SortValue, last.ID)
+	}
+	return results, next, nil
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification categories
+const (
+	CategoryMessages  = "messages"
+	CategoryOrders    = "orders"
+	CategoryReviews   = "reviews"
+	CategoryMarketing = "marketing"
+)
+
+var NotificationCategories = []string{CategoryMessages, CategoryOrders, CategoryReviews, CategoryMarketing}
+
+// NotificationPreference is which channels a user wants one category of
+// notification on. Users without a row for a category get its default.
+type NotificationPreference struct {
+	UserID    uint      `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Category  string    `json:"category" gorm:"primaryKey"`
+	Push      bool      `json:"push" gorm:"not null"`
+	InApp     bool      `json:"in_app" gorm:"not null"` // the inbox and event streams
+	Email     bool      `json:"email" gorm:"not null"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	User      User      `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// defaultNotificationPreference is the preference of a user who has not
+// chosen one. Marketing is opt-in.
+func defaultNotificationPreference(userID uint, category string) NotificationPreference {
+	on := category != CategoryMarketing
+	return NotificationPreference{UserID: userID, Category: category, Push: on, InApp: on, Email: on}
+}
+
+type UpdateNotificationPreferenceInput struct {
+	Push  *bool `json:"push" binding:"required"`
+	InApp *bool `json:"in_app" binding:"required"`
+	Email *bool `json:"email" binding:"required"`
+}
+
+// QuietHours is a daily window, in the user's timezone, during which push
+// notifications are held until it ends. Start and End are "HH:MM"; a window
+// whose end is before its start runs overnight.
+type QuietHours struct {
+	UserID    uint      `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Timezone  string    `json:"timezone" gorm:"not null"` // IANA name, e.g. America/New_York
+	Start     string    `json:"start" gorm:"column:start_time;not null"`
+	End       string    `json:"end" gorm:"column:end_time;not null"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	User      User      `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type UpdateQuietHoursInput struct {
+	Timezone string `json:"timezone" binding:"required,timezone"`
+	Start    string `json:"start" binding:"required,datetime=15:04"`
+	End      string `json:"end" binding:"required,datetime=15:04,nefield=Start"`
+}
+
+// FindNotificationPreferences returns the user's preference for every
+// category, filling in defaults for the ones they have not set
+func FindNotificationPreferences(userID uint) ([]NotificationPreference, error) {
+	var saved []NotificationPreference
+	if err := DB.Where("user_id = ?", userID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byCategory := make(map[string]NotificationPreference, len(saved))
+	for _, preference := range saved {
+		byCategory[preference.Category] = preference
+	}
+
+	preferences := make([]NotificationPreference, len(NotificationCategories))
+	for i, category := range NotificationCategories {
+		preference, ok := byCategory[category]
+		if !ok {
+			preference = defaultNotificationPreference(userID, category)
+		}
+		preferences[i] = preference
+	}
+	return preferences, nil
+}
+
+// GetNotificationPreference returns the user's preference for category using
+// tx. Notifications without a category, such as tests, go on every channel.
+func GetNotificationPreference(tx *gorm.DB, userID uint, category string) (NotificationPreference, error) {
+	if category == "" {
+		return NotificationPreference{UserID: userID, Push: true, InApp: true, Email: true}, nil
+	}
+	var preferences []NotificationPreference
+	err := tx.Where("user_id = ? AND category = ?", userID, category).Limit(1).Find(&preferences).Error
+	if err != nil {
+		return NotificationPreference{}, err
+	}
+	if len(preferences) == 0 {
+		return defaultNotificationPreference(userID, category), nil
+	}
+	return preferences[0], nil
+}
+
+// SaveNotificationPreference sets the user's preference for category
+func SaveNotificationPreference(userID uint, category string, input UpdateNotificationPreferenceInput) (*NotificationPreference, error) {
+	preference := NotificationPreference{
+		UserID:   userID,
+		Category: category,
+		Push:     *input.Push,
+		InApp:    *input.InApp,
+		Email:    *input.Email,
+	}
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"push", "in_app", "email", "updated_at"}),
+	}).Create(&preference).Error
+	if err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// GetQuietHours returns the user's quiet hours using tx, or nil if they have
+// none
+func GetQuietHours(tx *gorm.DB, userID uint) (*QuietHours, error) {
+	var quiet []QuietHours
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&quiet).Error; err != nil {
+		return nil, err
+	}
+	if len(quiet) == 0 {
+		return nil, nil
+	}
+	return &quiet[0], nil
+}
+
+// SaveQuietHours sets the user's quiet hours
+func SaveQuietHours(userID uint, input UpdateQuietHoursInput) (*QuietHours, error) {
+	quiet := QuietHours{UserID: userID, Timezone: input.Timezone, Start: input.Start, End: input.End}
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timezone", "start_time", "end_time", "updated_at"}),
+	}).Create(&quiet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &quiet, nil
+}
+
+// DeleteQuietHours turns off the user's quiet hours
+func DeleteQuietHours(userID uint) error {
+	return DB.Where("user_id = ?", userID).Delete(&QuietHours{}).Error
+}
+
+// EndAfter reports when the quiet hours that t falls in end, and false if t
+// is outside them
+func (q *QuietHours) EndAfter(t time.Time) (time.Time, bool, error) {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	start, err := minuteOfDay(q.Start)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	end, err := minuteOfDay(q.End)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	quiet := false
+	if start < end {
+		quiet = now >= start && now < end
+	} else if start > end {
+		quiet = now >= start || now < end
+	}
+	if !quiet {
+		return time.Time{}, false, nil
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, loc)
+	}
+	return until, true, nil
+}
+
+// minuteOfDay reads an "HH:MM" time as minutes after midnight
+func minuteOfDay(hhmm string) (int, error) {
+	parsed, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: %w", hhmm, err)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification is an entry in a user's inbox: a copy of every notification
+// sent to them, kept after the push itself is dismissed
+type Notification struct {
+	ID             uint              `json:"id" gorm:"primary_key"`
+	IdempotencyKey string            `json:"-" gorm:"uniqueIndex;not null"` // the key of the outbox notification that sent it
+	UserID         uint              `json:"-" gorm:"not null"`
+	Category       string            `json:"category" gorm:"not null;default:''"`
+	Title          string            `json:"title" gorm:"not null"`
+	Body           string            `json:"body" gorm:"not null"`
+	Data           map[string]string `json:"data" gorm:"type:jsonb;serializer:json;not null"`
+	ReadAt         *time.Time        `json:"read_at"`
+	CreatedAt      time.Time         `json:"created_at"`
+	User           User              `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type NotificationFilter struct {
+	Unread bool `form:"unread"` // only unread notifications
+	PageInput
+}
+
+// CreateNotification adds n to its user's inbox using tx. It reports false,
+// leaving n unsaved, if a notification with the same idempotency key is
+// already there.
+func CreateNotification(tx *gorm.DB, n *Notification) (bool, error) {
+	if n.Data == nil {
+		n.Data = map[string]string{}
+	}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoNothing: true,
+	}).Create(n)
+	return result.RowsAffected > 0, result.Error
+}
+
+// notificationKeyset pages through an inbox newest first
+var notificationKeyset = Keyset{Name: "notifications", ID: "id"}
+
+// FindNotifications returns one page of the user's inbox, newest first, and
+// the cursor for the next page
+func FindNotifications(userID uint, filter NotificationFilter) ([]Notification, string, error) {
+	query := DB.Where("user_id = ?", userID)
+	if filter.Unread {
+		query = query.Where("read_at IS NULL")
+	}
+	return Paginate(query, notificationKeyset, filter.PageInput, func(n *Notification) (string, uint) {
+		return "", n.ID
+	})
+}
+
+// CountUnreadNotifications returns the user's badge count
+func CountUnreadNotifications(userID uint) (int64, error) {
+	var unread int64
+	err := DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error
+	return unread, err
+}
+
+// MarkNotificationRead marks one of the user's notifications read. Returns
+// gorm.ErrRecordNotFound if the user has no such notification.
+func MarkNotificationRead(userID, notificationID uint) error {
+	var notification Notification
+	err := DB.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error
+	if err != nil {
+		return err
+	}
+	if notification.ReadAt != nil {
+		return nil
+	}
+	return DB.Model(&notification).Update("read_at", time.Now()).Error
+}
+
+// MarkAllNotificationsRead marks every notification in the user's inbox read
+func MarkAllNotificationsRead(userID uint) error {
+	return DB.Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Order statuses
+const (
+	OrderRequested  = "requested"
+	OrderAccepted   = "accepted"
+	OrderDeclined   = "declined"
+	OrderInProgress = "in_progress"
+	OrderCompleted  = "completed"
+	OrderCancelled  = "cancelled"
+	OrderDisputed   = "disputed"
+)
+
+// Orders in these statuses still need something from a party. Disputed
+// orders have no transition out, so they must not hold up account deletion.
+var openOrderStatuses = []string{OrderRequested, OrderAccepted, OrderInProgress}
+
+var (
+	ErrIllegalTransition = errors.New("illegal order status transition")
+	ErrNotOrderParty     = errors.New("user is not allowed to make this transition")
+)
+
+// Who may move an order between two statuses
+type orderActor int
+
+const (
+	actorBuyer orderActor = 1 << iota
+	actorSeller
+	actorEither = actorBuyer | actorSeller
+)
+
+// orderTransitions is the order state machine: from status -> to status -> allowed actors
+var orderTransitions = map[string]map[string]orderActor{
+	OrderRequested: {
+		OrderAccepted:  actorSeller,
+		OrderDeclined:  actorSeller,
+		OrderCancelled: actorEither,
+	},
+	OrderAccepted: {
+		OrderInProgress: actorSeller,
+		OrderCancelled:  actorEither,
+	},
+	OrderInProgress: {
+		OrderCompleted: actorSeller,
+		OrderCancelled: actorEither,
+		OrderDisputed:  actorEither,
+	},
+	OrderCompleted: {
+		OrderDisputed: actorEither,
+	},
+}
+
+type Order struct {
+	ID          uint      `json:"id" gorm:"primary_key"`
+	ListingID   uint      `json:"listing_id" gorm:"index;not null"`
+	BuyerID     uint      `json:"buyer_id" gorm:"index;not null"`
+	SellerID    uint      `json:"seller_id" gorm:"index;not null"`
+	Description string    `json:"description"`
+	PriceCents  int64     `json:"price_cents" gorm:"not null"`
+	Status      string    `json:"status" gorm:"index;not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Listing     Listing   `json:"-" gorm:"foreignKey:ListingID"`
+	Buyer       User      `json:"-" gorm:"foreignKey:BuyerID"`
+	Seller      User      `json:"-" gorm:"foreignKey:SellerID"`
+}
+
+// OrderTransition records every status change of an order
+type OrderTransition struct {
+	ID         uint      `json:"id" gorm:"primary_key"`
+	OrderID    uint      `json:"order_id" gorm:"index;not null"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status" gorm:"not null"`
+	ActorID    uint      `json:"actor_id" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	Order      Order     `json:"-" gorm:"foreignKey:OrderID"`
+	Actor      User      `json:"-" gorm:"foreignKey:ActorID"`
+}
+
+type CreateOrderInput struct {
+	ListingID   uint   `json:"listing_id" binding:"required"`
+	Description string `json:"description" binding:"max=5000"`
+	PriceCents  *int64 `json:"price_cents" binding:"omitempty,min=0"` // defaults to the listing price
+}
+
+// NotifyOrder queues the notifications for an order's new status using the
+// transaction that records transition
+type NotifyOrder func(tx *gorm.DB, transition *OrderTransition) error
+
+// CreateOrder inserts a new requested order along with its first transition
+func CreateOrder(order *Order, notify NotifyOrder) error {
+	order.Status = OrderRequested
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		transition := OrderTransition{
+			OrderID:  order.ID,
+			ToStatus: OrderRequested,
+			ActorID:  order.BuyerID,
+		}
+		if err := tx.Create(&transition).Error; err != nil {
+			return err
+		}
+		return notify(tx, &transition)
+	})
+}
+
+// FindUserOrders returns orders the user is party to, newest first.
+// role may be "buyer", "seller" or empty for both.
+func FindUserOrders(userID uint, role, status string) ([]Order, error) {
+	query := DB.Order("created_at DESC")
+	switch role {
+	case "buyer":
+		query = query.Where("buyer_id = ?", userID)
+	case "seller":
+		query = query.Where("seller_id = ?", userID)
+	default:
+		query = query.Where("buyer_id = ? OR seller_id = ?", userID, userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []Order
+	if err := query.Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetOrder finds an order by ID
+func GetOrder(id uint) (*Order, error) {
+	var order Order
+	if err := DB.First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// HasParty reports whether the user is the buyer or seller of the order
+func (o *Order) HasParty(userID uint) bool {
+	return o.BuyerID == userID || o.SellerID == userID
+}
+
+// Transitions returns the order's audit trail, oldest first
+func (o *Order) Transitions() ([]OrderTransition, error) {
+	var transitions []OrderTransition
+	err := DB.Where("order_id = ?", o.ID).Order("id").Find(&transitions).Error
+	return transitions, err
+}
+
+// Transition moves the order to the given status on behalf of actorID,
+// rejecting moves the state machine does not allow. The order row is locked
+// so concurrent transitions are applied one at a time.
+func (o *Order) Transition(actorID uint, to string, notify NotifyOrder) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(o, o.ID).Error; err != nil {
+			return err
+		}
+
+		allowed, ok := orderTransitions[o.Status][to]
+		if !ok {
+			return ErrIllegalTransition
+		}
+		var actor orderActor
+		if actorID == o.BuyerID {
+			actor |= actorBuyer
+		}
+		if actorID == o.SellerID {
+			actor |= actorSeller
+		}
+		if allowed&actor == 0 {
+			return ErrNotOrderParty
+		}
+
+		from := o.Status
+		if err := tx.Model(o).Update("status", to).Error; err != nil {
+			return err
+		}
+		transition := OrderTransition{
+			OrderID:    o.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			ActorID:    actorID,
+		}
+		if err := tx.Create(&transition).Error; err != nil {
+			return err
+		}
+		return notify(tx, &transition)
+	})
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outbox notification statuses
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // gave up after too many failed attempts
+)
+
+// OutboxNotification is a push notification waiting to be sent. Rows are
+// written in the same transaction as the change they announce, then sent by
+// the outbox workers. IdempotencyKey names the event the notification is
+// for, so enqueueing the same event twice only sends it once.
+type OutboxNotification struct {
+	ID             uint              `json:"id" gorm:"primary_key"`
+	IdempotencyKey string            `json:"idempotency_key" gorm:"uniqueIndex;not null"`
+	UserID         uint              `json:"user_id" gorm:"index;not null"`
+	Category       string            `json:"category" gorm:"not null;default:''"`
+	Title          string            `json:"title" gorm:"not null"`
+	Body           string            `json:"body" gorm:"not null"`
+	Data           map[string]string `json:"data" gorm:"type:jsonb;serializer:json;not null"`
+	Push           bool              `json:"push" gorm:"not null"`   // send to the user's devices
+	InApp          bool              `json:"in_app" gorm:"not null"` // publish to the user's event streams
+	Status         string            `json:"status" gorm:"not null;default:pending"`
+	Attempts       int               `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at" gorm:"not null"`
+	LastError      string            `json:"last_error"`
+	PendingTokens  []string          `json:"pending_tokens" gorm:"type:jsonb;serializer:json"` // devices a partly sent notification has left to reach; nil means all
+	SentAt         *time.Time        `json:"sent_at"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	User           User              `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// EnqueueNotification adds n to the outbox using tx, doing nothing if a
+// notification with the same idempotency key already exists
+func EnqueueNotification(tx *gorm.DB, n *OutboxNotification) error {
+	n.Status = OutboxPending
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = time.Now()
+	}
+	if n.Data == nil {
+		n.Data = map[string]string{}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoNothing: true,
+	}).Create(n).Error
+}
+
+// ClaimNotifications takes up to limit due notifications for sending and
+// counts the attempt. Each is leased for lease: until then no other worker
+// claims it, and if this worker dies before reporting back it is retried
+// once the lease runs out. SKIP LOCKED lets workers claim concurrently
+// without waiting on each other.
+func ClaimNotifications(limit int, lease time.Duration) ([]OutboxNotification, error) {
+	var claimed []OutboxNotification
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", OutboxPending, time.Now()).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&claimed).Error
+		if err != nil || len(claimed) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(claimed))
+		for i := range claimed {
+			ids[i] = claimed[i].ID
+			claimed[i].Attempts++
+		}
+		return tx.Model(&OutboxNotification{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": time.Now().Add(lease),
+		}).Error
+	})
+	return claimed, err
+}
+
+// MarkSent records that the notification was delivered
+func (n *OutboxNotification) MarkSent() error {
+	now := time.Now()
+	n.Status, n.SentAt, n.LastError = OutboxSent, &now, ""
+	return DB.Model(n).Updates(map[string]interface{}{
+		"status":     n.Status,
+		"sent_at":    n.SentAt,
+		"last_error": n.LastError,
+	}).Error
+}
+
+// MarkFailed records a failed attempt, scheduling the next one at retryAt,
+// or moving the notification to the dead-letter state if retryAt is nil.
+// If pending is not nil, the next attempt only sends to those tokens.
+func (n *OutboxNotification) MarkFailed(cause error, retryAt *time.Time, pending []string) error {
+	updates := map[string]interface{}{"last_error": cause.Error()}
+	if pending != nil {
+		encoded, err := json.Marshal(pending)
+		if err != nil {
+			return err
+		}
+		n.PendingTokens = pending
+		updates["pending_tokens"] = gorm.Expr("?::jsonb", string(encoded))
+	}
+	if retryAt != nil {
+		updates["next_attempt_at"] = *retryAt
+	} else {
+		updates["status"] = OutboxDead
+	}
+	return DB.Model(n).Updates(updates).Error
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultPageSize = 20
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageInput is the page of a list a client asks for. Cursor is the
+// next_cursor of the previous page, empty for the first page.
+type PageInput struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// Keyset orders a list by Column and then by ID, both ascending or both
+// descending, so every row has a unique position a cursor can resume from.
+// Leave Column empty to order by ID alone.
+type Keyset struct {
+	Name   string // recorded in cursors so one order's cursor is not used with another
+	Column string
+	ID     string
+	Asc    bool
+	Parse  func(string) (interface{}, error) // reads a Column value back from a cursor
+}
+
+// Keyset Parse functions
+func parseString(s string) (interface{}, error) { return s, nil }
+func parseFloat(s string) (interface{}, error)  { return strconv.ParseFloat(s, 64) }
+func parseInt(s string) (interface{}, error)    { return strconv.ParseInt(s, 10, 64) }
+func parseTime(s string) (interface{}, error)   { return time.Parse(time.RFC3339Nano, s) }
+
+// Paginate fetches one page of query in keyset order. key returns a row's
+// Column value, formatted so Parse can read it, and its ID. It returns the
+// rows and the cursor for the next page, which is empty on the last page.
+func Paginate[T any](query *gorm.DB, keyset Keyset, page PageInput, key func(*T) (string, uint)) ([]T, string, error) {
+	limit := page.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	cmp, dir := "<", "DESC"
+	if keyset.Asc {
+		cmp, dir = ">", "ASC"
+	}
+	if page.Cursor != "" {
+		value, id, err := decodeCursor(page.Cursor, keyset.Name, keyset.Parse)
+		if err != nil {
+			return nil, "", err
+		}
+		if keyset.Column == "" {
+			query = query.Where(keyset.ID+" "+cmp+" ?", id)
+		} else {
+			query = query.Where("("+keyset.Column+", "+keyset.ID+") "+cmp+" (?, ?)", value, id)
+		}
+	}
+	if keyset.Column != "" {
+		query = query.Order(keyset.Column + " " + dir)
+	}
+	query = query.Order(keyset.ID + " " + dir).Limit(limit)
+
+	var rows []T
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(rows) == limit {
+		value, id := key(&rows[len(rows)-1])
+		next = encodeCursor(keyset.Name, value, id)
+	}
+	return rows, next, nil
+}
+
+// Cursors are opaque to clients: base64 of "name|value|id"
+func encodeCursor(name, value string, id uint) string {
+	raw := fmt.Sprintf("%s|%s|%d", name, value, id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor reads a cursor made by encodeCursor for the named order. The
+// value is nil if parse is.
+func decodeCursor(cursor, name string, parse func(string) (interface{}, error)) (interface{}, uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	// The value itself may contain "|", so split the name and id off the ends
+	prefix, rest, ok := strings.Cut(string(raw), "|")
+	sep := strings.LastIndex(rest, "|")
+	if !ok || sep < 0 || prefix != name {
+		return nil, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(rest[sep+1:], 10, 64)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var value interface{}
+	if parse != nil {
+		if value, err = parse(rest[:sep]); err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+	}
+	return value, id, nil
+}
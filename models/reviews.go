@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrderNotCompleted = errors.New("order is not completed")
+	ErrAlreadyReviewed   = errors.New("order already reviewed by this user")
+)
+
+// Review is one side's rating of the other for a completed order
+type Review struct {
+	ID         uint      `json:"id" gorm:"primary_key"`
+	OrderID    uint      `json:"order_id" gorm:"not null;uniqueIndex:idx_reviews_order_reviewer"`
+	ReviewerID uint      `json:"reviewer_id" gorm:"not null;uniqueIndex:idx_reviews_order_reviewer"`
+	RevieweeID uint      `json:"reviewee_id" gorm:"index;not null"`
+	Rating     int       `json:"rating" gorm:"not null;check:rating BETWEEN 1 AND 5"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	Order      Order     `json:"-" gorm:"foreignKey:OrderID"`
+	Reviewer   User      `json:"-" gorm:"foreignKey:ReviewerID"`
+	Reviewee   User      `json:"-" gorm:"foreignKey:RevieweeID"`
+}
+
+type CreateReviewInput struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Body   string `json:"body" binding:"max=2000"`
+}
+
+// NotifyReview queues the notifications for a new review using the
+// transaction that stores it
+type NotifyReview func(tx *gorm.DB, review *Review) error
+
+// CreateReview stores the reviewer's review of the other party to a
+// completed order and folds the rating into the reviewee's aggregate
+func CreateReview(order *Order, reviewerID uint, input CreateReviewInput, notify NotifyReview) (*Review, error) {
+	if order.Status != OrderCompleted {
+		return nil, ErrOrderNotCompleted
+	}
+
+	review := Review{
+		OrderID:    order.ID,
+		ReviewerID: reviewerID,
+		RevieweeID: order.BuyerID,
+		Rating:     input.Rating,
+		Body:       input.Body,
+	}
+	if reviewerID == order.BuyerID {
+		review.RevieweeID = order.SellerID
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyReviewed
+			}
+			return err
+		}
+		// Postgres evaluates every SET expression against the old row, so
+		// the average uses the pre-update count
+		err := tx.Model(&User{}).Where("id = ?", review.RevieweeID).Updates(map[string]interface{}{
+			"rating_average": gorm.Expr("(rating_average * rating_count + ?) / (rating_count + 1)", review.Rating),
+			"rating_count":   gorm.Expr("rating_count + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return notify(tx, &review)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// FindUserReviews returns reviews received by the user, newest first
+func FindUserReviews(userID uint) ([]Review, error) {
+	var reviews []Review
+	err := DB.Where("reviewee_id = ?", userID).Order("created_at DESC").Find(&reviews).Error
+	return reviews, err
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cornellEmailDomain is the domain of the addresses that earn the verified
+// Cornell student badge
+const cornellEmailDomain = "cornell.edu"
+
+// SellerProfile is what a user shows buyers beyond their name. Response
+// stats are kept incrementally as the seller replies to messages.
+type SellerProfile struct {
+	UserID               uint      `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Bio                  string    `json:"bio"`
+	Skills               []string  `json:"skills" gorm:"type:jsonb;serializer:json;not null"`
+	ResponseCount        int64     `json:"response_count" gorm:"not null;default:0"`
+	ResponseSecondsTotal int64     `json:"-" gorm:"not null;default:0"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	User                 User      `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// PortfolioItem is an example of a seller's past work
+type PortfolioItem struct {
+	ID          uint      `json:"id" gorm:"primary_key"`
+	UserID      uint      `json:"-" gorm:"index;not null"`
+	Title       string    `json:"title" gorm:"not null"`
+	Description string    `json:"description"`
+	MediaURLs   []string  `json:"media_urls" gorm:"type:jsonb;serializer:json;not null"` // storage references of uploaded images or videos
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	User        User      `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type UpdateSellerProfileInput struct {
+	Bio    string   `json:"bio" binding:"max=2000"`
+	Skills []string `json:"skills" binding:"max=20,dive,min=1,max=40"`
+}
+
+type CreatePortfolioItemInput struct {
+	Title       string   `json:"title" binding:"required,max=120"`
+	Description string   `json:"description" binding:"max=2000"`
+	MediaURLs   []string `json:"media_urls" binding:"max=10,dive,min=1,max=2048"`
+}
+
+// Profile is everything shown on a user's public profile page
+type Profile struct {
+	User                   PublicUser      `json:"user"`
+	VerifiedCornellStudent bool            `json:"verified_cornell_student"`
+	Seller                 *SellerProfile  `json:"seller"` // nil if the user does not sell
+	AverageResponseSeconds *int64          `json:"average_response_seconds"`
+	Portfolio              []PortfolioItem `json:"portfolio"`
+}
+
+// VerifiedCornellStudent reports whether the user signed in with a verified
+// Cornell email address
+func (u *User) VerifiedCornellStudent() bool {
+	return u.EmailVerified && strings.HasSuffix(strings.ToLower(u.Email), "@"+cornellEmailDomain)
+}
+
+// GetProfile assembles the public profile of a user
+func GetProfile(userID uint) (*Profile, error) {
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	profile := Profile{
+		User:                   user.Public(),
+		VerifiedCornellStudent: user.VerifiedCornellStudent(),
+		Portfolio:              []PortfolioItem{},
+	}
+
+	var seller SellerProfile
+	err := DB.Where("user_id = ?", userID).Limit(1).Find(&seller).Error
+	if err != nil {
+		return nil, err
+	}
+	if seller.UserID != 0 {
+		profile.Seller = &seller
+		if seller.ResponseCount > 0 {
+			average := seller.ResponseSecondsTotal / seller.ResponseCount
+			profile.AverageResponseSeconds = &average
+		}
+	}
+
+	if err := DB.Where("user_id = ?", userID).Order("id DESC").Find(&profile.Portfolio).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// SaveSellerProfile creates or updates the user's seller profile, keeping
+// their response stats
+func SaveSellerProfile(userID uint, input UpdateSellerProfileInput) (*SellerProfile, error) {
+	skills := input.Skills
+	if skills == nil {
+		skills = []string{}
+	}
+	profile := SellerProfile{UserID: userID, Bio: input.Bio, Skills: skills}
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"bio", "skills", "updated_at"}),
+	}).Create(&profile).Error
+	if err != nil {
+		return nil, err
+	}
+	// Reload for the stats an existing profile already had
+	if err := DB.First(&profile, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// CreatePortfolioItem adds an item to the user's portfolio
+func CreatePortfolioItem(userID uint, input CreatePortfolioItemInput) (*PortfolioItem, error) {
+	media := input.MediaURLs
+	if media == nil {
+		media = []string{}
+	}
+	item := PortfolioItem{UserID: userID, Title: input.Title, Description: input.Description, MediaURLs: media}
+	if err := DB.Create(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// DeletePortfolioItem removes one of the user's portfolio items. Returns
+// gorm.ErrRecordNotFound if the user has no such item.
+func DeletePortfolioItem(userID, itemID uint) error {
+	result := DB.Where("id = ? AND user_id = ?", itemID, userID).Delete(&PortfolioItem{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// recordResponse updates the sender's response stats when their message at
+// sentAt answers messages from the other member. The wait is measured from
+// the first message the sender had not yet replied to. It must run before
+// the reply is inserted.
+func recordResponse(tx *gorm.DB, conversationID, senderID uint, sentAt time.Time) error {
+	var waitingSince sql.NullTime
+	err := tx.Model(&Message{}).
+		Select("MIN(created_at)").
+		Where("conversation_id = ? AND sender_id <> ?", conversationID, senderID).
+		Where("id > (SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = ? AND sender_id = ?)", conversationID, senderID).
+		Row().Scan(&waitingSince)
+	if err != nil || !waitingSince.Valid {
+		return err
+	}
+
+	seconds := int64(sentAt.Sub(waitingSince.Time).Seconds())
+	if seconds < 0 {
+		seconds = 0
+	}
+	// Only sellers have a profile to update
+	return tx.Model(&SellerProfile{}).Where("user_id = ?", senderID).Updates(map[string]interface{}{
+		"response_count":         gorm.Expr("response_count + 1"),
+		"response_seconds_total": gorm.Expr("response_seconds_total + ?", seconds),
+	}).Error
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrSessionExpired     = errors.New("session has expired")
+	ErrRefreshTokenReused = errors.New("refresh token was already rotated")
+)
+
+// Session is one signed-in device. Its refresh token is rotated on every
+// use and only the hash of the current one is stored. FamilyID identifies
+// the chain of tokens descended from a single sign-in, so presenting a token
+// that has already been rotated revokes everything in the family.
+type Session struct {
+	ID               uint       `json:"id" gorm:"primary_key"`
+	UserID           uint       `json:"-" gorm:"index;not null"`
+	FamilyID         string     `json:"-" gorm:"index;not null"`
+	RefreshTokenHash string     `json:"-" gorm:"not null"`
+	UserAgent        string     `json:"user_agent"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"-"`
+	Current          bool       `json:"current" gorm:"-"` // whether this is the requesting device
+	User             User       `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IssueRefreshToken creates the refresh token for a session, returning it
+// and its expiry
+type IssueRefreshToken func(sessionID uint) (token string, expiresAt time.Time, err error)
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartSession creates a session for a new sign-in and stores the hash of
+// the refresh token issue creates for it
+func StartSession(userID uint, userAgent string, issue IssueRefreshToken) (*Session, error) {
+	now := time.Now()
+	session := Session{
+		UserID:     userID,
+		FamilyID:   rand.Text(),
+		UserAgent:  userAgent,
+		LastUsedAt: now,
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		token, expiresAt, err := issue(session.ID)
+		if err != nil {
+			return err
+		}
+		session.RefreshTokenHash = hashToken(token)
+		session.ExpiresAt = expiresAt
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_token_hash": session.RefreshTokenHash,
+			"expires_at":         session.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSession finds a session by ID
+func GetSession(id uint) (*Session, error) {
+	var session Session
+	if err := DB.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindActiveSessions returns the user's unrevoked, unexpired sessions, most
+// recently used first
+func FindActiveSessions(userID uint) ([]Session, error) {
+	var sessions []Session
+	err := DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Active reports whether the session can still be used
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// Revoke ends the session and unregisters the FCM tokens of its device
+func (s *Session) Revoke() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", s.ID).Delete(&FCMToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(s).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+	})
+}
+
+// RevokeUserSessions ends all of the user's sessions and unregisters all of
+// their FCM tokens
+func RevokeUserSessions(userID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&FCMToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// Rotate exchanges the session's current refresh token for a new one from
+// issue. If presented is a token the session has already rotated away from,
+// the whole family is revoked and ErrRefreshTokenReused is returned.
+func (s *Session) Rotate(presented, userAgent string, issue IssueRefreshToken) error {
+	reused := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(s, s.ID).Error; err != nil {
+			return err
+		}
+		if s.RevokedAt != nil {
+			return ErrSessionRevoked
+		}
+		if time.Now().After(s.ExpiresAt) {
+			return ErrSessionExpired
+		}
+		if subtle.ConstantTimeCompare([]byte(hashToken(presented)), []byte(s.RefreshTokenHash)) != 1 {
+			// Commit the revocation rather than rolling it back with an error
+			reused = true
+			return revokeFamily(tx, s.FamilyID)
+		}
+
+		token, expiresAt, err := issue(s.ID)
+		if err != nil {
+			return err
+		}
+		s.RefreshTokenHash = hashToken(token)
+		s.ExpiresAt = expiresAt
+		s.LastUsedAt = time.Now()
+		s.UserAgent = userAgent
+		return tx.Model(s).Updates(map[string]interface{}{
+			"refresh_token_hash": s.RefreshTokenHash,
+			"expires_at":         s.ExpiresAt,
+			"last_used_at":       s.LastUsedAt,
+			"user_agent":         s.UserAgent,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if reused {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// revokeFamily revokes every live session descended from the same sign-in
+// and unregisters the FCM tokens of their devices, as Revoke does
+func revokeFamily(tx *gorm.DB, familyID string) error {
+	var ids []uint
+	err := tx.Model(&Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	if err := tx.Where("session_id IN ?", ids).Delete(&FCMToken{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Session{}).Where("id IN ?", ids).Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRotateWithReusedTokenRevokesFamilyAndItsDevices(t *testing.T) {
+	mock := mockDB(t)
+	session := Session{ID: 3}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "sessions" WHERE "sessions"."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "refresh_token_hash", "expires_at"}).
+			AddRow(3, 7, "family", hashToken("current"), time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT "id" FROM "sessions" WHERE family_id = \$1 AND revoked_at IS NULL`).
+		WithArgs("family").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+	mock.ExpectExec(`DELETE FROM "fcm_tokens" WHERE session_id IN \(\$1,\$2\)`).
+		WithArgs(3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1,"updated_at"=\$2 WHERE id IN \(\$3,\$4\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	issue := func(sessionID uint) (string, time.Time, error) {
+		t.Fatal("a reused token must not be exchanged")
+		return "", time.Time{}, nil
+	}
+	if err := session.Rotate("already-rotated", "test", issue); err != ErrRefreshTokenReused {
+		t.Fatalf("Rotate() = %v, want ErrRefreshTokenReused", err)
+	}
+}
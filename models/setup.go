@@ -22,7 +22,7 @@ func ConnectDatabase() error {
     
     dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
 
-    database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+    database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
     if err != nil {
         return fmt.Errorf("failed to connect to database: %w", err)
     }
@@ -36,11 +36,7 @@ func ConnectDatabase() error {
     sqlDB.SetMaxOpenConns(100)
     sqlDB.SetConnMaxLifetime(time.Hour)
 
-    // Make sure to include all models to migrate here
-    err = database.AutoMigrate(&User{})
-    if err != nil {
-        return fmt.Errorf("failed to migrate database: %w", err)
-    }
+    // Schema changes are made by the versioned files in migrations/, not AutoMigrate
 
     DB = database
     log.Println("Database connected successfully")
@@ -1,53 +1,384 @@
 package models
 
 import (
+    "errors"
     "log"
+    "strings"
+    "time"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// ErrOpenOrders is returned when deleting an account that still has orders
+// in progress
+var ErrOpenOrders = errors.New("user has open orders")
+
+// User roles, from least to most privileged
+const (
+  RoleUser      = "user"
+  RoleModerator = "moderator"
+  RoleAdmin     = "admin"
 )
 
+// User is the database row for an account. Handlers must not serialize it
+// directly; respond with Public or Private instead.
 type User struct {
-  ID            uint   `json:"id" gorm:"primary_key"`
-  Firebase_UID  string `json:"firebase_uid" gorm:"uniqueIndex"`
-  Refresh_Token string `json:"refresh_token"`
-  FirstName     string `json:"firstname"`
-  LastName      string `json:"lastname"`
-  Email         string `json:"email"`
-  CreatedAt     string `json:"created_at"`
-  UpdatedAt     string `json:"updated_at"`
+  ID             uint           `json:"id" gorm:"primary_key"`
+  Firebase_UID   string         `json:"-" gorm:"uniqueIndex"`
+  FirstName      string         `json:"firstname"`
+  LastName       string         `json:"lastname"`
+  Email          string         `json:"email"`
+  EmailVerified  bool           `json:"email_verified" gorm:"not null;default:false"`
+  Bio            string         `json:"bio"`
+  Pronouns       string         `json:"pronouns"`
+  GraduationYear *int           `json:"graduation_year"`
+  Major          string         `json:"major"`
+  PhotoURL       string         `json:"photo_url"`
+  FirebaseName   string         `json:"-"` // last display name seen in a Firebase token
+  Role           string         `json:"role" gorm:"not null;default:user"`
+  RatingAverage  float64        `json:"rating_average" gorm:"not null;default:0"`
+  RatingCount    int            `json:"rating_count" gorm:"not null;default:0"`
+  BannedAt       *time.Time     `json:"banned_at"`
+  CreatedAt      time.Time      `json:"created_at"`
+  UpdatedAt      time.Time      `json:"updated_at"`
+  DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PublicUser is what anyone may see about a user
+type PublicUser struct {
+  ID             uint    `json:"id"`
+  FirstName      string  `json:"firstname"`
+  LastName       string  `json:"lastname"`
+  Bio            string  `json:"bio"`
+  Pronouns       string  `json:"pronouns"`
+  GraduationYear *int    `json:"graduation_year"`
+  Major          string  `json:"major"`
+  PhotoURL       string  `json:"photo_url"`
+  RatingAverage  float64 `json:"rating_average"`
+  RatingCount    int     `json:"rating_count"`
+}
+
+// PrivateUser is a user's own profile, also shown to admins
+type PrivateUser struct {
+  PublicUser
+  FirebaseUID   string     `json:"firebase_uid"`
+  Email         string     `json:"email"`
+  EmailVerified bool       `json:"email_verified"`
+  Role          string     `json:"role"`
+  BannedAt      *time.Time `json:"banned_at"`
+  CreatedAt     time.Time  `json:"created_at"`
+  UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Public returns the fields of u that are safe to show to anyone
+func (u *User) Public() PublicUser {
+  return PublicUser{
+    ID:             u.ID,
+    FirstName:      u.FirstName,
+    LastName:       u.LastName,
+    Bio:            u.Bio,
+    Pronouns:       u.Pronouns,
+    GraduationYear: u.GraduationYear,
+    Major:          u.Major,
+    PhotoURL:       u.PhotoURL,
+    RatingAverage:  u.RatingAverage,
+    RatingCount:    u.RatingCount,
+  }
+}
+
+// Private returns the fields of u that only u and admins may see
+func (u *User) Private() PrivateUser {
+  return PrivateUser{
+    PublicUser:    u.Public(),
+    FirebaseUID:   u.Firebase_UID,
+    Email:         u.Email,
+    EmailVerified: u.EmailVerified,
+    Role:          u.Role,
+    BannedAt:      u.BannedAt,
+    CreatedAt:     u.CreatedAt,
+    UpdatedAt:     u.UpdatedAt,
+  }
+}
+
+// PrivateUsers converts a list of users with Private
+func PrivateUsers(users []User) []PrivateUser {
+  out := make([]PrivateUser, len(users))
+  for i := range users {
+    out[i] = users[i].Private()
+  }
+  return out
+}
+
+// User list sort orders
+const (
+  UserSortRecent = "recent"
+  UserSortOldest = "oldest"
+  UserSortEmail  = "email"
+)
+
+var userKeysets = map[string]Keyset{
+  UserSortRecent: {Name: UserSortRecent, Column: "users.created_at", ID: "users.id", Parse: parseTime},
+  UserSortOldest: {Name: UserSortOldest, Column: "users.created_at", ID: "users.id", Asc: true, Parse: parseTime},
+  UserSortEmail:  {Name: UserSortEmail, Column: "COALESCE(users.email, '')", ID: "users.id", Asc: true, Parse: parseString},
+}
+
+// UserFilter narrows and orders the admin user list
+type UserFilter struct {
+  Domain        string     `form:"domain"` // email domain, e.g. cornell.edu
+  Role          string     `form:"role" binding:"omitempty,oneof=user moderator admin"`
+  Banned        *bool      `form:"banned"`
+  CreatedAfter  *time.Time `form:"created_after"`
+  CreatedBefore *time.Time `form:"created_before"`
+  Sort          string     `form:"sort" binding:"omitempty,oneof=recent oldest email"` // defaults to recent
+  PageInput
+}
+
+// FindUsers returns one page of the users matching filter, the number of
+// matching users across all pages and the cursor for the next page
+func FindUsers(filter UserFilter) ([]User, int64, string, error) {
+  query := DB.Model(&User{})
+  if filter.Domain != "" {
+    query = query.Where("lower(split_part(users.email, '@', 2)) = ?", strings.ToLower(filter.Domain))
+  }
+  if filter.Role != "" {
+    query = query.Where("users.role = ?", filter.Role)
+  }
+  if filter.Banned != nil {
+    if *filter.Banned {
+      query = query.Where("users.banned_at IS NOT NULL")
+    } else {
+      query = query.Where("users.banned_at IS NULL")
+    }
+  }
+  if filter.CreatedAfter != nil {
+    query = query.Where("users.created_at >= ?", *filter.CreatedAfter)
+  }
+  if filter.CreatedBefore != nil {
+    query = query.Where("users.created_at < ?", *filter.CreatedBefore)
+  }
+  // Share the filters between the count and the page without either
+  // adding clauses to the other
+  query = query.Session(&gorm.Session{})
+
+  var total int64
+  if err := query.Count(&total).Error; err != nil {
+    return nil, 0, "", err
+  }
+
+  sortName := filter.Sort
+  if sortName == "" {
+    sortName = UserSortRecent
+  }
+  users, next, err := Paginate(query, userKeysets[sortName], filter.PageInput, func(u *User) (string, uint) {
+    if sortName == UserSortEmail {
+      return u.Email, u.ID
+    }
+    return u.CreatedAt.Format(time.RFC3339Nano), u.ID
+  })
+  if err != nil {
+    return nil, 0, "", err
+  }
+  return users, total, next, nil
 }
 
+type UpdateRoleInput struct {
+  Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+// The email comes from the caller's Firebase token, never the request body
 type CreateUserInput struct {
   FirstName  string `json:"firstname" binding:"required"`
   LastName   string `json:"lastname" binding:"required"`
-  Email      string `json:"email" binding:"required"`
 }
 
-// FindOrCreateUser finds an existing user by Firebase UID or creates a new one
-func FindOrCreateUser(firebaseUID, email, firstName, lastName string) (*User, error) {
+// Fields left nil are not changed
+type UpdateProfileInput struct {
+  FirstName      *string `json:"firstname" binding:"omitempty,min=1,max=50"`
+  LastName       *string `json:"lastname" binding:"omitempty,min=1,max=50"`
+  Bio            *string `json:"bio" binding:"omitempty,max=1000"`
+  Pronouns       *string `json:"pronouns" binding:"omitempty,max=40"`
+  GraduationYear *int    `json:"graduation_year" binding:"omitempty,eq=0|min=1900,max=2100"` // 0 removes it
+  Major          *string `json:"major" binding:"omitempty,max=100"`
+  PhotoURL       *string `json:"photo_url" binding:"omitempty,max=2048"` // storage reference of the uploaded photo; "" removes it
+}
+
+// FirebaseIdentity is who a verified Firebase ID token says the caller is
+type FirebaseIdentity struct {
+  UID           string
+  Email         string
+  EmailVerified bool
+  Name          string // display name, e.g. "Jane Doe"
+}
+
+// splitName splits a display name into first name and the rest
+func splitName(name string) (string, string) {
+  parts := strings.Fields(name)
+  if len(parts) == 0 {
+    return "", ""
+  }
+  return parts[0], strings.Join(parts[1:], " ")
+}
+
+// FindOrCreateUser returns the user for a Firebase account, creating it on
+// first sign-in. It is a single upsert, so concurrent first sign-ins all get
+// the same row. The email is kept in sync with Firebase on every sign-in,
+// while the name is only overwritten when the Firebase display name itself
+// changes, so edits made through PATCH /me stick.
+func FindOrCreateUser(identity FirebaseIdentity) (*User, error) {
+  firstName, lastName := splitName(identity.Name)
+  user := User{
+    Firebase_UID:  identity.UID,
+    Email:         identity.Email,
+    EmailVerified: identity.EmailVerified,
+    FirstName:     firstName,
+    LastName:      lastName,
+    FirebaseName:  identity.Name,
+  }
+
+  nameChanged := "excluded.firebase_name <> '' AND excluded.firebase_name IS DISTINCT FROM users.firebase_name"
+  err := DB.Clauses(
+    clause.OnConflict{
+      Columns: []clause.Column{{Name: "firebase_uid"}},
+      DoUpdates: clause.Set{
+        {Column: clause.Column{Name: "email"}, Value: gorm.Expr("CASE WHEN excluded.email <> '' THEN excluded.email ELSE users.email END")},
+        {Column: clause.Column{Name: "email_verified"}, Value: gorm.Expr("excluded.email_verified")},
+        {Column: clause.Column{Name: "first_name"}, Value: gorm.Expr("CASE WHEN " + nameChanged + " THEN excluded.first_name ELSE users.first_name END")},
+        {Column: clause.Column{Name: "last_name"}, Value: gorm.Expr("CASE WHEN " + nameChanged + " THEN excluded.last_name ELSE users.last_name END")},
+        {Column: clause.Column{Name: "firebase_name"}, Value: gorm.Expr("CASE WHEN excluded.firebase_name <> '' THEN excluded.firebase_name ELSE users.firebase_name END")},
+        {Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("excluded.updated_at")},
+      },
+    },
+    // Read back the whole row, which for an existing user is mostly not
+    // what was inserted
+    clause.Returning{},
+  ).Create(&user).Error
+  if err != nil {
+    log.Printf("[ERROR] Failed to create or update user (Firebase UID: %s): %v", identity.UID, err)
+    return nil, err
+  }
+  return &user, nil
+}
+
+// FindUserByFirebaseUID finds a user by their Firebase UID
+func FindUserByFirebaseUID(firebaseUID string) (*User, error) {
 	var user User
-	
-	// Try to find existing user
-	result := DB.Where("firebase_uid = ?", firebaseUID).First(&user)
-	
-	if result.Error != nil {
-		log.Printf("[ERROR] User not found by Firebase UID (%s): %v", firebaseUID, result.Error)
-		// User doesn't exist, create new one
-		user = User{
-			Firebase_UID: firebaseUID,
-			Email:        email,
-			FirstName:    firstName,
-			LastName:     lastName,
-		}
-		
-		if err := DB.Create(&user).Error; err != nil {
-			log.Printf("[ERROR] Failed to create user (Firebase UID: %s): %v", firebaseUID, err)
-			return nil, err
-		}
+	if err := DB.Where("firebase_uid = ?", firebaseUID).First(&user).Error; err != nil {
+		return nil, err
 	}
-	
 	return &user, nil
 }
 
-// UpdateRefreshToken updates the user's refresh token
-func (u *User) UpdateRefreshToken(refreshToken string) error {
-	return DB.Model(u).Update("refresh_token", refreshToken).Error
-}
\ No newline at end of file
+// FindUserByEmail finds a user by email address
+func FindUserByEmail(email string) (*User, error) {
+	var user User
+	if err := DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetRole changes the user's role
+func (u *User) SetRole(role string) error {
+	return DB.Model(u).Update("role", role).Error
+}
+
+// ApplyProfile copies the set fields of input onto the user and saves them
+func (u *User) ApplyProfile(input UpdateProfileInput) error {
+  if input.FirstName != nil {
+    u.FirstName = *input.FirstName
+  }
+  if input.LastName != nil {
+    u.LastName = *input.LastName
+  }
+  if input.Bio != nil {
+    u.Bio = *input.Bio
+  }
+  if input.Pronouns != nil {
+    u.Pronouns = *input.Pronouns
+  }
+  if input.GraduationYear != nil {
+    // null can't be told apart from leaving the field out, so 0 clears it
+    if *input.GraduationYear == 0 {
+      u.GraduationYear = nil
+    } else {
+      u.GraduationYear = input.GraduationYear
+    }
+  }
+  if input.Major != nil {
+    u.Major = *input.Major
+  }
+  if input.PhotoURL != nil {
+    u.PhotoURL = *input.PhotoURL
+  }
+  // Only the profile columns, so a concurrent role change or ban is kept
+  return DB.Model(u).
+    Select("first_name", "last_name", "bio", "pronouns", "graduation_year", "major", "photo_url").
+    Updates(u).Error
+}
+
+// Delete closes the user's account: their sessions are revoked, their FCM
+// tokens, listings and seller profile deleted, and their personal details
+// erased before the row is soft deleted. Clearing firebase_uid lets the same
+// Firebase account sign up again. Accounts with open orders cannot be
+// deleted.
+func (u *User) Delete() error {
+  return DB.Transaction(func(tx *gorm.DB) error {
+    var open int64
+    err := tx.Model(&Order{}).
+      Where("(buyer_id = ? OR seller_id = ?) AND status IN ?", u.ID, u.ID, openOrderStatuses).
+      Count(&open).Error
+    if err != nil {
+      return err
+    }
+    if open > 0 {
+      return ErrOpenOrders
+    }
+
+    if err := tx.Where("user_id = ?", u.ID).Delete(&FCMToken{}).Error; err != nil {
+      return err
+    }
+    err = tx.Model(&Session{}).
+      Where("user_id = ? AND revoked_at IS NULL", u.ID).
+      Update("revoked_at", time.Now()).Error
+    if err != nil {
+      return err
+    }
+    if err := tx.Where("owner_id = ?", u.ID).Delete(&Listing{}).Error; err != nil {
+      return err
+    }
+    if err := tx.Where("user_id = ?", u.ID).Delete(&PortfolioItem{}).Error; err != nil {
+      return err
+    }
+    if err := tx.Where("user_id = ?", u.ID).Delete(&SellerProfile{}).Error; err != nil {
+      return err
+    }
+    if err := tx.Where("user_id = ?", u.ID).Delete(&Notification{}).Error; err != nil {
+      return err
+    }
+    if err := tx.Where("user_id = ?", u.ID).Delete(&NotificationPreference{}).Error; err != nil {
+      return err
+    }
+    if err := tx.Where("user_id = ?", u.ID).Delete(&QuietHours{}).Error; err != nil {
+      return err
+    }
+
+    err = tx.Model(u).Updates(map[string]interface{}{
+      "firebase_uid":    nil,
+      "email":           nil,
+      "email_verified":  false,
+      "firebase_name":   "",
+      "first_name":      "",
+      "last_name":       "",
+      "bio":             "",
+      "pronouns":        "",
+      "graduation_year": nil,
+      "major":           "",
+      "photo_url":       "",
+    }).Error
+    if err != nil {
+      return err
+    }
+    return tx.Delete(u).Error
+  })
+}
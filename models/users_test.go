@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestDeleteWithDisputedOrder(t *testing.T) {
+	mock := mockDB(t)
+	user := User{ID: 7, Firebase_UID: "uid", Email: "ada@cornell.edu"}
+
+	mock.ExpectBegin()
+	// The user's only order is disputed, which is not an open status
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "orders"`).
+		WithArgs(user.ID, user.ID, OrderRequested, OrderAccepted, OrderInProgress).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`DELETE FROM "fcm_tokens"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "listings" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "portfolio_items"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "seller_profiles"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "notifications"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "notification_preferences"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "quiet_hours"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "users" SET .*"email"=`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "users" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := user.Delete(); err != nil {
+		t.Fatalf("Delete() = %v, want the account deleted", err)
+	}
+}
+
+func TestDeleteWithOpenOrder(t *testing.T) {
+	mock := mockDB(t)
+	user := User{ID: 7}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	if err := user.Delete(); err != ErrOpenOrders {
+		t.Fatalf("Delete() = %v, want ErrOpenOrders", err)
+	}
+}
+
+func TestApplyProfileClearsGraduationYear(t *testing.T) {
+	mock := mockDB(t)
+	year := 2027
+	user := User{ID: 7, FirstName: "Ada", GraduationYear: &year}
+	zero := 0
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET .*"graduation_year"=\$5`).
+		WithArgs("Ada", "", "", "", nil, "", "", sqlmock.AnyArg(), user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := user.ApplyProfile(UpdateProfileInput{GraduationYear: &zero}); err != nil {
+		t.Fatal(err)
+	}
+	if user.GraduationYear != nil {
+		t.Errorf("GraduationYear = %d, want cleared", *user.GraduationYear)
+	}
+}
+
+func TestUpdateProfileInputGraduationYear(t *testing.T) {
+	for year, valid := range map[int]bool{0: true, 2027: true, 1500: false, 3000: false} {
+		input := UpdateProfileInput{GraduationYear: &year}
+		err := binding.Validator.ValidateStruct(input)
+		if valid && err != nil {
+			t.Errorf("graduation_year %d rejected: %v", year, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("graduation_year %d accepted", year)
+		}
+	}
+}
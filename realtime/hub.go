@@ -0,0 +1,153 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// Event types streamed to clients
+const (
+	EventMessage      = "message"
+	EventOrder        = "order"
+	EventNotification = "notification"
+)
+
+// subscriberBuffer is how many undelivered events a subscriber may have
+// queued before it is considered too slow and disconnected
+const subscriberBuffer = 32
+
+// Event is a single update addressed to one user
+type Event struct {
+	UserID uint            `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Backplane fans events out across server replicas. Publish must deliver
+// every event to the deliver func passed to Start on every replica,
+// including the one that published it. A Postgres LISTEN/NOTIFY
+// implementation only needs to NOTIFY the JSON-encoded event in Publish and
+// call deliver for each notification its listener receives in Start.
+type Backplane interface {
+	Start(ctx context.Context, deliver func(Event)) error
+	Publish(ctx context.Context, event Event) error
+}
+
+// Subscription receives the events for one connected client
+type Subscription struct {
+	userID uint
+	events chan Event
+	hub    *Hub
+	once   sync.Once
+}
+
+// Events is closed when the subscription is closed, either by the client
+// disconnecting or by the hub dropping a slow subscriber
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// Hub is an in-process pub/sub of events keyed by user, so every connection
+// a user has open gets each event addressed to them
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[*Subscription]struct{}
+	backplane   Backplane
+}
+
+// NewHub creates a hub. With a nil backplane events are only delivered to
+// subscribers connected to this process.
+func NewHub(backplane Backplane) *Hub {
+	return &Hub{
+		subscribers: make(map[uint]map[*Subscription]struct{}),
+		backplane:   backplane,
+	}
+}
+
+// Start begins receiving events from the backplane, if there is one
+func (h *Hub) Start(ctx context.Context) error {
+	if h.backplane == nil {
+		return nil
+	}
+	return h.backplane.Start(ctx, h.deliver)
+}
+
+// Subscribe registers a new connection for the user
+func (h *Hub) Subscribe(userID uint) *Subscription {
+	s := &Subscription{
+		userID: userID,
+		events: make(chan Event, subscriberBuffer),
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][s] = struct{}{}
+	return s
+}
+
+// Publish sends an event of the given type to all of the user's connections.
+// data is JSON encoded.
+func (h *Hub) Publish(userID uint, eventType string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode %s event for user %d: %v", eventType, userID, err)
+		return
+	}
+	event := Event{UserID: userID, Type: eventType, Data: raw}
+
+	if h.backplane == nil {
+		h.deliver(event)
+		return
+	}
+	if err := h.backplane.Publish(context.Background(), event); err != nil {
+		log.Printf("[ERROR] Failed to publish %s event for user %d: %v", eventType, userID, err)
+	}
+}
+
+// deliver hands an event to this process's subscribers for its user,
+// dropping any subscriber whose buffer is full
+func (h *Hub) deliver(event Event) {
+	var slow []*Subscription
+
+	h.mu.RLock()
+	for s := range h.subscribers[event.UserID] {
+		select {
+		case s.events <- event:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		log.Printf("[WARN] Dropping slow event subscriber for user %d", s.userID)
+		h.remove(s)
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	s.once.Do(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[s.userID], s)
+		if len(h.subscribers[s.userID]) == 0 {
+			delete(h.subscribers, s.userID)
+		}
+		close(s.events)
+	})
+}
+
+// DefaultHub is the process-wide hub. Replace it before serving requests to
+// plug in a backplane.
+var DefaultHub = NewHub(nil)
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// FakePusher is an in-memory Pusher that records what it is asked to send,
+// for exercising notifications without Firebase
+type FakePusher struct {
+	mu         sync.Mutex
+	messages   []*messaging.Message
+	multicasts []*messaging.MulticastMessage
+
+	// Err, if set, fails every call outright
+	Err error
+	// TokenErrors fails sends to particular tokens
+	TokenErrors map[string]error
+}
+
+func NewFakePusher() *FakePusher {
+	return &FakePusher{TokenErrors: make(map[string]error)}
+}
+
+func (p *FakePusher) Send(ctx context.Context, message *messaging.Message) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return "", p.Err
+	}
+	p.messages = append(p.messages, message)
+	if err := p.TokenErrors[message.Token]; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("fake-message-%d", len(p.messages)), nil
+}
+
+func (p *FakePusher) SendMulticast(ctx context.Context, message *messaging.MulticastMessage) (*messaging.BatchResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	p.multicasts = append(p.multicasts, message)
+
+	response := &messaging.BatchResponse{}
+	for i, token := range message.Tokens {
+		if err := p.TokenErrors[token]; err != nil {
+			response.Responses = append(response.Responses, &messaging.SendResponse{Error: err})
+			response.FailureCount++
+			continue
+		}
+		response.Responses = append(response.Responses, &messaging.SendResponse{
+			Success:   true,
+			MessageID: fmt.Sprintf("fake-multicast-%d-%d", len(p.multicasts), i),
+		})
+		response.SuccessCount++
+	}
+	return response, nil
+}
+
+// Messages returns the single-device messages sent so far
+func (p *FakePusher) Messages() []*messaging.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*messaging.Message(nil), p.messages...)
+}
+
+// Multicasts returns the multicast messages sent so far
+func (p *FakePusher) Multicasts() []*messaging.MulticastMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*messaging.MulticastMessage(nil), p.multicasts...)
+}
+
+// Reset forgets everything sent so far
+func (p *FakePusher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages, p.multicasts = nil, nil
+}
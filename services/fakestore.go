@@ -0,0 +1,153 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cuappdev/hustle-backend/models"
+	"gorm.io/gorm"
+)
+
+// FakeNotificationStore is an in-memory NotificationStore that records the
+// notifications it is asked to store, for exercising notifications without a
+// database. Its transactions hand fn a nil *gorm.DB.
+type FakeNotificationStore struct {
+	mu            sync.Mutex
+	notifications []models.Notification
+	enqueued      []models.OutboxNotification
+	deleted       []string
+
+	// Tokens are the users' device tokens
+	Tokens map[uint][]string
+	// Unread is the unread inbox count of every user
+	Unread int64
+	// Preferences are the users' preferences by category; categories without
+	// one use every channel except marketing, as in the database
+	Preferences map[string]models.NotificationPreference
+	// QuietHours are the users' quiet hours
+	QuietHours map[uint]*models.QuietHours
+}
+
+func NewFakeNotificationStore() *FakeNotificationStore {
+	return &FakeNotificationStore{
+		Tokens:      make(map[uint][]string),
+		Preferences: make(map[string]models.NotificationPreference),
+		QuietHours:  make(map[uint]*models.QuietHours),
+	}
+}
+
+// Transaction runs fn and, if it fails, forgets what fn stored
+func (s *FakeNotificationStore) Transaction(fn func(tx *gorm.DB) error) error {
+	s.mu.Lock()
+	notifications, enqueued := len(s.notifications), len(s.enqueued)
+	s.mu.Unlock()
+
+	err := fn(nil)
+	if err != nil {
+		s.mu.Lock()
+		s.notifications, s.enqueued = s.notifications[:notifications], s.enqueued[:enqueued]
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *FakeNotificationStore) GetNotificationPreference(tx *gorm.DB, userID uint, category string) (models.NotificationPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if preference, ok := s.Preferences[category]; ok && category != "" {
+		preference.UserID = userID
+		return preference, nil
+	}
+	on := category != models.CategoryMarketing
+	return models.NotificationPreference{UserID: userID, Category: category, Push: on, InApp: on, Email: on}, nil
+}
+
+func (s *FakeNotificationStore) GetQuietHours(tx *gorm.DB, userID uint) (*models.QuietHours, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.QuietHours[userID], nil
+}
+
+func (s *FakeNotificationStore) CreateNotification(tx *gorm.DB, n *models.Notification) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.notifications {
+		if existing.IdempotencyKey == n.IdempotencyKey {
+			return false, nil
+		}
+	}
+	n.ID = uint(len(s.notifications) + 1)
+	s.notifications = append(s.notifications, *n)
+	return true, nil
+}
+
+func (s *FakeNotificationStore) EnqueueNotification(tx *gorm.DB, n *models.OutboxNotification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.enqueued {
+		if existing.IdempotencyKey == n.IdempotencyKey {
+			return nil
+		}
+	}
+	n.Status = models.OutboxPending
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = time.Now()
+	}
+	s.enqueued = append(s.enqueued, *n)
+	return nil
+}
+
+func (s *FakeNotificationStore) UserTokens(userID uint) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.Tokens[userID]...), nil
+}
+
+func (s *FakeNotificationStore) DeleteToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, token)
+	return nil
+}
+
+func (s *FakeNotificationStore) CountUnreadNotifications(userID uint) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Unread, nil
+}
+
+func (s *FakeNotificationStore) ClaimNotifications(limit int, lease time.Duration) ([]models.OutboxNotification, error) {
+	return nil, nil
+}
+
+func (s *FakeNotificationStore) MarkSent(n *models.OutboxNotification) error {
+	return nil
+}
+
+func (s *FakeNotificationStore) MarkFailed(n *models.OutboxNotification, cause error, retryAt *time.Time, pending []string) error {
+	return nil
+}
+
+// Notifications returns the inbox entries stored so far
+func (s *FakeNotificationStore) Notifications() []models.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Notification(nil), s.notifications...)
+}
+
+// Enqueued returns the notifications queued for delivery so far
+func (s *FakeNotificationStore) Enqueued() []models.OutboxNotification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.OutboxNotification(nil), s.enqueued...)
+}
+
+// Deleted returns the device tokens deleted so far
+func (s *FakeNotificationStore) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
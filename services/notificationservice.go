@@ -2,66 +2,254 @@ package services
 
 import (
     "context"
+    "fmt"
+    "log"
+    "strconv"
+    "time"
+
     "firebase.google.com/go/v4/messaging"
-    "github.com/cuappdev/hustle-backend/auth"
     "github.com/cuappdev/hustle-backend/models"
+    "github.com/cuappdev/hustle-backend/realtime"
+    "gorm.io/gorm"
 )
 
 type NotificationPayload struct {
-    Title string            `json:"title"`
-    Body  string            `json:"body"`
-    Data  map[string]string `json:"data,omitempty"`
+    Category string            `json:"category,omitempty"` // one of models.NotificationCategories; empty ignores preferences
+    Title    string            `json:"title"`
+    Body     string            `json:"body"`
+    Data     map[string]string `json:"data,omitempty"`
+}
+
+// NotificationService queues notifications and delivers them to devices
+// through a Pusher and to open event streams through a realtime.Hub
+type NotificationService struct {
+    store  NotificationStore
+    pusher Pusher
+    hub    *realtime.Hub
+}
+
+// NewNotificationService creates the service shared by every handler;
+// construct it once at startup
+func NewNotificationService(store NotificationStore, pusher Pusher, hub *realtime.Hub) *NotificationService {
+    return &NotificationService{
+        store:  store,
+        pusher: pusher,
+        hub:    hub,
+    }
+}
+
+// Publish sends a realtime event to the user's open event streams
+func (s *NotificationService) Publish(userID uint, eventType string, data interface{}) {
+    s.hub.Publish(userID, eventType, data)
 }
 
-// sends notification to all user's devices
-func SendToUser(userID uint, payload NotificationPayload) error {
-    tokens, err := models.GetUserTokens(userID)
-    if err != nil || len(tokens) == 0 {
+// Subscribe opens an event stream for the user
+func (s *NotificationService) Subscribe(userID uint) *realtime.Subscription {
+    return s.hub.Subscribe(userID)
+}
+
+// Notify adds a notification to the user's inbox and queues it for their
+// devices and open event streams using tx, so it is only sent if tx commits.
+// Only the channels the user wants for the payload's category are used, and
+// pushes are held until the user's quiet hours end. key identifies the event
+// being announced, e.g. "message:42": notifying the same key again does
+// nothing.
+func (s *NotificationService) Notify(tx *gorm.DB, userID uint, key string, payload NotificationPayload) error {
+    preference, err := s.store.GetNotificationPreference(tx, userID, payload.Category)
+    if err != nil {
         return err
     }
-    
+    if !preference.Push && !preference.InApp {
+        return nil
+    }
+
+    data := make(map[string]string, len(payload.Data)+1)
+    for k, v := range payload.Data {
+        data[k] = v
+    }
+
+    if preference.InApp {
+        notification := models.Notification{
+            IdempotencyKey: key,
+            UserID:         userID,
+            Category:       payload.Category,
+            Title:          payload.Title,
+            Body:           payload.Body,
+            Data:           payload.Data,
+        }
+        created, err := s.store.CreateNotification(tx, &notification)
+        if err != nil || !created {
+            return err
+        }
+        // Tell clients which inbox entry to mark read when the push is opened
+        data["notification_id"] = strconv.FormatUint(uint64(notification.ID), 10)
+    }
+
+    n := &models.OutboxNotification{
+        IdempotencyKey: key,
+        UserID:         userID,
+        Category:       payload.Category,
+        Title:          payload.Title,
+        Body:           payload.Body,
+        Data:           data,
+        Push:           preference.Push,
+        InApp:          preference.InApp,
+    }
+    if preference.Push {
+        quiet, err := s.store.GetQuietHours(tx, userID)
+        if err != nil {
+            return err
+        }
+        if quiet != nil {
+            until, ok, err := quiet.EndAfter(time.Now())
+            if err != nil {
+                log.Printf("[WARN] Ignoring invalid quiet hours of user %d: %v", userID, err)
+            } else if ok {
+                n.NextAttemptAt = until
+            }
+        }
+    }
+    return s.store.EnqueueNotification(tx, n)
+}
+
+// queues a notification that is not part of a larger change
+func (s *NotificationService) SendToUser(userID uint, key string, payload NotificationPayload) error {
+    return s.store.Transaction(func(tx *gorm.DB) error {
+        return s.Notify(tx, userID, key, payload)
+    })
+}
+
+// deliver sends a notification from the outbox to the user's open event
+// streams and devices. If some devices should be tried again, it returns an
+// error along with their tokens; a nil slice with an error means the whole
+// attempt should be repeated, unless the error is errBadPayload.
+func (s *NotificationService) deliver(ctx context.Context, n *models.OutboxNotification) ([]string, error) {
+    payload := NotificationPayload{Category: n.Category, Title: n.Title, Body: n.Body, Data: n.Data}
+    // Event streams only need it once, however many attempts the push takes
+    if n.InApp && n.Attempts == 1 {
+        s.hub.Publish(n.UserID, realtime.EventNotification, payload)
+    }
+    if !n.Push {
+        return nil, nil
+    }
+
+    tokens, err := s.store.UserTokens(n.UserID)
+    if err != nil {
+        return nil, err
+    }
+    if n.PendingTokens != nil {
+        // Retry only the devices an earlier attempt missed that are still registered
+        tokens = intersectTokens(n.PendingTokens, tokens)
+    }
+    if len(tokens) == 0 {
+        return nil, nil
+    }
+
+    unread, err := s.store.CountUnreadNotifications(n.UserID)
+    if err != nil {
+        return nil, err
+    }
+    badge := int(unread)
+
     message := &messaging.MulticastMessage{
         Notification: &messaging.Notification{
             Title: payload.Title,
-            Body:  payload.Body,
+            Body:  truncateBody(payload.Body),
         },
         Data:   payload.Data,
         Tokens: tokens,
+        // If a retry does reach a device twice, it replaces the first copy.
+        // The badge is the user's unread inbox count when it is sent.
+        Android: &messaging.AndroidConfig{
+            CollapseKey:  n.IdempotencyKey,
+            Notification: &messaging.AndroidNotification{NotificationCount: &badge},
+        },
+        APNS: &messaging.APNSConfig{
+            Headers: map[string]string{"apns-collapse-id": n.IdempotencyKey},
+            Payload: &messaging.APNSPayload{Aps: &messaging.Aps{Badge: &badge}},
+        },
     }
-    
-    client := auth.GetMessagingClient()
-    response, err := client.SendMulticast(context.Background(), message)
-    
-    // Remove invalid tokens
-    if response.FailureCount > 0 {
-        for idx, resp := range response.Responses {
-            if !resp.Success {
-                models.DeleteToken(tokens[idx])
+
+    response, err := s.pusher.SendMulticast(ctx, message)
+    if err != nil {
+        // Nothing was sent, so every token is retried
+        pushMetrics.Add(metricTokensRetried, int64(len(tokens)))
+        return nil, err
+    }
+
+    var retry []string
+    var lastErr, payloadErr error
+    for idx, resp := range response.Responses {
+        if resp.Success {
+            pushMetrics.Add(metricTokensSent, 1)
+            continue
+        }
+        switch classifyPushError(resp.Error) {
+        case tokenPrune:
+            pushMetrics.Add(metricTokensPruned, 1)
+            if err := s.store.DeleteToken(tokens[idx]); err != nil {
+                log.Printf("[ERROR] Failed to delete invalid FCM token: %v", err)
             }
+        case tokenRetry:
+            pushMetrics.Add(metricTokensRetried, 1)
+            retry = append(retry, tokens[idx])
+            lastErr = resp.Error
+        case tokenBadPayload:
+            pushMetrics.Add(metricTokensFailed, 1)
+            payloadErr = resp.Error
+        default:
+            pushMetrics.Add(metricTokensFailed, 1)
+            log.Printf("[WARN] Notification %d could not be sent to a device of user %d: %v", n.ID, n.UserID, resp.Error)
         }
     }
-    
-    return err
+
+    if payloadErr != nil {
+        return nil, fmt.Errorf("%w: %v", errBadPayload, payloadErr)
+    }
+    if len(retry) > 0 {
+        return retry, fmt.Errorf("%d of %d devices not reached: %w", len(retry), len(tokens), lastErr)
+    }
+    return nil, nil
+}
+
+// intersectTokens returns the tokens in want that are also in have
+func intersectTokens(want, have []string) []string {
+    registered := make(map[string]bool, len(have))
+    for _, token := range have {
+        registered[token] = true
+    }
+    tokens := []string{}
+    for _, token := range want {
+        if registered[token] {
+            tokens = append(tokens, token)
+        }
+    }
+    return tokens
 }
 
 // sends to a specific token
-func SendToToken(token string, payload NotificationPayload) error {
+func (s *NotificationService) SendToToken(ctx context.Context, token string, payload NotificationPayload) error {
     message := &messaging.Message{
         Notification: &messaging.Notification{
             Title: payload.Title,
-            Body:  payload.Body,
+            Body:  truncateBody(payload.Body),
         },
         Data:  payload.Data,
         Token: token,
     }
-    
-    client := auth.GetMessagingClient()
-    _, err := client.Send(context.Background(), message)
-    
-    if err != nil {
-        // Remove invalid token
-        models.DeleteToken(token)
+
+    _, err := s.pusher.Send(ctx, message)
+    if err == nil {
+        pushMetrics.Add(metricTokensSent, 1)
+        return nil
+    }
+
+    // Remove the token only if FCM says it is no longer valid
+    if classifyPushError(err) == tokenPrune {
+        pushMetrics.Add(metricTokensPruned, 1)
+        if err := s.store.DeleteToken(token); err != nil {
+            log.Printf("[ERROR] Failed to delete invalid FCM token: %v", err)
+        }
     }
-    
     return err
-}
\ No newline at end of file
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/cuappdev/hustle-backend/models"
+	"github.com/cuappdev/hustle-backend/realtime"
+	"google.golang.org/api/option"
+	"gorm.io/gorm"
+)
+
+// fcmError returns the error the messaging client reports when FCM answers a
+// send with the given HTTP status, FCM error code and message
+func fcmError(t *testing.T, status int, code, message string) error {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error": {"status": "ERROR", "message": "%s", "details": [{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError", "errorCode": "%s"}]}}`, message, code)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	app, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: "test"},
+		option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Send(ctx, &messaging.Message{Token: "token"})
+	if err == nil {
+		t.Fatalf("expected FCM to fail with %s", code)
+	}
+	return err
+}
+
+func TestClassifyPushError(t *testing.T) {
+	tests := []struct {
+		status  int
+		code    string
+		message string
+		want    tokenAction
+	}{
+		{http.StatusNotFound, "UNREGISTERED", "Requested entity was not found.", tokenPrune},
+		{http.StatusForbidden, "SENDER_ID_MISMATCH", "SenderId mismatch", tokenPrune},
+		{http.StatusBadRequest, "INVALID_ARGUMENT", "The registration token is not a valid FCM registration token", tokenPrune},
+		{http.StatusBadRequest, "INVALID_ARGUMENT", "Message is too big", tokenBadPayload},
+		{http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid value at 'message.android.collapse_key'", tokenBadPayload},
+		{http.StatusTooManyRequests, "QUOTA_EXCEEDED", "Quota exceeded", tokenRetry},
+		{http.StatusInternalServerError, "INTERNAL", "Internal error", tokenRetry},
+		{http.StatusUnauthorized, "THIRD_PARTY_AUTH_ERROR", "APNs certificate rejected", tokenKeep},
+	}
+	for _, tt := range tests {
+		if got := classifyPushError(fcmError(t, tt.status, tt.code, tt.message)); got != tt.want {
+			t.Errorf("classifyPushError(%s: %s) = %v, want %v", tt.code, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverPrunesInvalidTokensAndRetriesTransientFailures(t *testing.T) {
+	store := NewFakeNotificationStore()
+	store.Tokens[7] = []string{"ok", "gone", "busy", "misconfigured"}
+	store.Unread = 3
+	pusher := NewFakePusher()
+	pusher.TokenErrors["gone"] = fcmError(t, http.StatusNotFound, "UNREGISTERED", "Requested entity was not found.")
+	pusher.TokenErrors["busy"] = fcmError(t, http.StatusTooManyRequests, "QUOTA_EXCEEDED", "Quota exceeded")
+	pusher.TokenErrors["misconfigured"] = fcmError(t, http.StatusUnauthorized, "THIRD_PARTY_AUTH_ERROR", "APNs certificate rejected")
+	hub := realtime.NewHub(nil)
+	sub := hub.Subscribe(7)
+	defer sub.Close()
+	service := NewNotificationService(store, pusher, hub)
+
+	n := &models.OutboxNotification{
+		ID:             1,
+		IdempotencyKey: "message:1",
+		UserID:         7,
+		Title:          "Hi",
+		Body:           "Hello",
+		Push:           true,
+		InApp:          true,
+		Attempts:       1,
+	}
+	pending, err := service.deliver(context.Background(), n)
+	if err == nil {
+		t.Fatal("expected an error so the busy device is retried")
+	}
+	if !slices.Equal(pending, []string{"busy"}) {
+		t.Errorf("pending = %v, want [busy]", pending)
+	}
+	if !slices.Equal(store.Deleted(), []string{"gone"}) {
+		t.Errorf("deleted = %v, want [gone]", store.Deleted())
+	}
+
+	multicasts := pusher.Multicasts()
+	if len(multicasts) != 1 {
+		t.Fatalf("sent %d multicasts, want 1", len(multicasts))
+	}
+	if badge := multicasts[0].APNS.Payload.Aps.Badge; badge == nil || *badge != 3 {
+		t.Errorf("APNs badge = %v, want 3", badge)
+	}
+
+	select {
+	case event := <-sub.Events():
+		if event.Type != realtime.EventNotification {
+			t.Errorf("published %s event, want %s", event.Type, realtime.EventNotification)
+		}
+	default:
+		t.Error("no event published to the user's stream")
+	}
+}
+
+func TestDeliverRetriesOnlyPendingTokens(t *testing.T) {
+	store := NewFakeNotificationStore()
+	store.Tokens[7] = []string{"ok", "busy"}
+	pusher := NewFakePusher()
+	service := NewNotificationService(store, pusher, realtime.NewHub(nil))
+
+	n := &models.OutboxNotification{
+		ID:            1,
+		UserID:        7,
+		Push:          true,
+		Attempts:      2,
+		PendingTokens: []string{"busy", "unregistered-since"},
+	}
+	pending, err := service.deliver(context.Background(), n)
+	if err != nil || pending != nil {
+		t.Fatalf("deliver = %v, %v; want success", pending, err)
+	}
+
+	multicasts := pusher.Multicasts()
+	if len(multicasts) != 1 || !slices.Equal(multicasts[0].Tokens, []string{"busy"}) {
+		t.Errorf("sent to %v, want only [busy]", multicasts)
+	}
+}
+
+func TestDeliverRetriesEveryTokenWhenTheSendFails(t *testing.T) {
+	store := NewFakeNotificationStore()
+	store.Tokens[7] = []string{"a", "b"}
+	pusher := NewFakePusher()
+	pusher.Err = fmt.Errorf("connection refused")
+	service := NewNotificationService(store, pusher, realtime.NewHub(nil))
+
+	pending, err := service.deliver(context.Background(), &models.OutboxNotification{ID: 1, UserID: 7, Push: true, Attempts: 1})
+	if err == nil {
+		t.Fatal("expected the failed send to be retried")
+	}
+	if pending != nil {
+		t.Errorf("pending = %v, want nil to retry every device", pending)
+	}
+	if len(store.Deleted()) != 0 {
+		t.Errorf("deleted %v after a failed send", store.Deleted())
+	}
+}
+
+func TestDeliverDeadLettersRejectedPayloadWithoutPruning(t *testing.T) {
+	store := NewFakeNotificationStore()
+	store.Tokens[7] = []string{"a", "b"}
+	pusher := NewFakePusher()
+	tooBig := fcmError(t, http.StatusBadRequest, "INVALID_ARGUMENT", "Message is too big")
+	pusher.TokenErrors["a"] = tooBig
+	pusher.TokenErrors["b"] = tooBig
+	service := NewNotificationService(store, pusher, realtime.NewHub(nil))
+
+	pending, err := service.deliver(context.Background(), &models.OutboxNotification{ID: 1, UserID: 7, Push: true, Attempts: 1})
+	if !errors.Is(err, errBadPayload) {
+		t.Fatalf("deliver error = %v, want errBadPayload", err)
+	}
+	if pending != nil {
+		t.Errorf("pending = %v, want nil", pending)
+	}
+	if len(store.Deleted()) != 0 {
+		t.Errorf("deleted %v for a rejected payload", store.Deleted())
+	}
+}
+
+func TestSendToUserSkipsTurnedOffCategories(t *testing.T) {
+	store := NewFakeNotificationStore()
+	store.Preferences[models.CategoryOrders] = models.NotificationPreference{Push: false, InApp: false}
+	service := NewNotificationService(store, NewFakePusher(), realtime.NewHub(nil))
+
+	payload := NotificationPayload{Category: models.CategoryOrders, Title: "Order update"}
+	if err := service.SendToUser(7, "order_transition:1", payload); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.Notifications(); len(n) != 0 {
+		t.Errorf("stored inbox entries %v, want none", n)
+	}
+	if n := store.Enqueued(); len(n) != 0 {
+		t.Errorf("queued %v, want nothing", n)
+	}
+}
+
+func TestNotifyAddsInboxEntryAndQueuesIt(t *testing.T) {
+	store := NewFakeNotificationStore()
+	service := NewNotificationService(store, NewFakePusher(), realtime.NewHub(nil))
+
+	payload := NotificationPayload{Category: models.CategoryMessages, Title: "Ada", Body: "Hi", Data: map[string]string{"type": "message"}}
+	err := store.Transaction(func(tx *gorm.DB) error {
+		return service.Notify(tx, 7, "message:1", payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inbox := store.Notifications()
+	if len(inbox) != 1 || inbox[0].UserID != 7 || inbox[0].Title != "Ada" {
+		t.Fatalf("inbox = %+v, want the message", inbox)
+	}
+	queued := store.Enqueued()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d notifications, want 1", len(queued))
+	}
+	if !queued[0].Push || !queued[0].InApp {
+		t.Errorf("queued push=%v in_app=%v, want both", queued[0].Push, queued[0].InApp)
+	}
+	if id := queued[0].Data["notification_id"]; id != "1" {
+		t.Errorf("notification_id = %q, want the inbox entry's id", id)
+	}
+	if queued[0].Data["type"] != "message" {
+		t.Errorf("data = %v, want the payload's data", queued[0].Data)
+	}
+}
+
+func TestNotifyWithoutInAppOnlyQueuesThePush(t *testing.T) {
+	store := NewFakeNotificationStore()
+	store.Preferences[models.CategoryReviews] = models.NotificationPreference{Push: true, InApp: false}
+	service := NewNotificationService(store, NewFakePusher(), realtime.NewHub(nil))
+
+	payload := NotificationPayload{Category: models.CategoryReviews, Title: "New review"}
+	if err := service.SendToUser(7, "review:1", payload); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.Notifications(); len(n) != 0 {
+		t.Errorf("stored inbox entries %v, want none", n)
+	}
+	queued := store.Enqueued()
+	if len(queued) != 1 || !queued[0].Push || queued[0].InApp {
+		t.Fatalf("queued %+v, want one push-only notification", queued)
+	}
+	if _, ok := queued[0].Data["notification_id"]; ok {
+		t.Error("push links to an inbox entry that does not exist")
+	}
+}
+
+func TestNotifyHoldsPushesDuringQuietHours(t *testing.T) {
+	store := NewFakeNotificationStore()
+	now := time.Now().UTC()
+	store.QuietHours[7] = &models.QuietHours{
+		Timezone: "UTC",
+		Start:    now.Add(-time.Hour).Format("15:04"),
+		End:      now.Add(time.Hour).Format("15:04"),
+	}
+	service := NewNotificationService(store, NewFakePusher(), realtime.NewHub(nil))
+
+	if err := service.SendToUser(7, "message:1", NotificationPayload{Category: models.CategoryMessages}); err != nil {
+		t.Fatal(err)
+	}
+	queued := store.Enqueued()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d notifications, want 1", len(queued))
+	}
+	if wait := time.Until(queued[0].NextAttemptAt); wait < 58*time.Minute || wait > time.Hour {
+		t.Errorf("push held for %v, want until the quiet hours end in about an hour", wait)
+	}
+}
+
+func TestSendToUserQueuesEachKeyOnce(t *testing.T) {
+	store := NewFakeNotificationStore()
+	service := NewNotificationService(store, NewFakePusher(), realtime.NewHub(nil))
+
+	for range 2 {
+		if err := service.SendToUser(7, "message:1", NotificationPayload{Title: "Hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(store.Notifications()); n != 1 {
+		t.Errorf("stored %d inbox entries, want 1", n)
+	}
+	if n := len(store.Enqueued()); n != 1 {
+		t.Errorf("queued %d notifications, want 1", n)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"time"
+
+	"github.com/cuappdev/hustle-backend/models"
+	"gorm.io/gorm"
+)
+
+// NotificationStore is the storage NotificationService sends from: users'
+// device tokens and inboxes, and the outbox
+type NotificationStore interface {
+	// Transaction runs fn in a new database transaction. The methods taking
+	// a tx run in that transaction, or in one the caller started.
+	Transaction(fn func(tx *gorm.DB) error) error
+	GetNotificationPreference(tx *gorm.DB, userID uint, category string) (models.NotificationPreference, error)
+	GetQuietHours(tx *gorm.DB, userID uint) (*models.QuietHours, error)
+	CreateNotification(tx *gorm.DB, n *models.Notification) (bool, error)
+	EnqueueNotification(tx *gorm.DB, n *models.OutboxNotification) error
+	UserTokens(userID uint) ([]string, error)
+	DeleteToken(token string) error
+	CountUnreadNotifications(userID uint) (int64, error)
+	ClaimNotifications(limit int, lease time.Duration) ([]models.OutboxNotification, error)
+	MarkSent(n *models.OutboxNotification) error
+	MarkFailed(n *models.OutboxNotification, cause error, retryAt *time.Time, pending []string) error
+}
+
+// DBNotificationStore is the NotificationStore backed by the database
+type DBNotificationStore struct{}
+
+func NewDBNotificationStore() *DBNotificationStore {
+	return &DBNotificationStore{}
+}
+
+func (DBNotificationStore) Transaction(fn func(tx *gorm.DB) error) error {
+	return models.DB.Transaction(fn)
+}
+
+func (DBNotificationStore) GetNotificationPreference(tx *gorm.DB, userID uint, category string) (models.NotificationPreference, error) {
+	return models.GetNotificationPreference(tx, userID, category)
+}
+
+func (DBNotificationStore) GetQuietHours(tx *gorm.DB, userID uint) (*models.QuietHours, error) {
+	return models.GetQuietHours(tx, userID)
+}
+
+func (DBNotificationStore) CreateNotification(tx *gorm.DB, n *models.Notification) (bool, error) {
+	return models.CreateNotification(tx, n)
+}
+
+func (DBNotificationStore) EnqueueNotification(tx *gorm.DB, n *models.OutboxNotification) error {
+	return models.EnqueueNotification(tx, n)
+}
+
+func (DBNotificationStore) UserTokens(userID uint) ([]string, error) {
+	return models.GetUserTokens(userID)
+}
+
+func (DBNotificationStore) DeleteToken(token string) error {
+	return models.DeleteToken(token)
+}
+
+func (DBNotificationStore) CountUnreadNotifications(userID uint) (int64, error) {
+	return models.CountUnreadNotifications(userID)
+}
+
+func (DBNotificationStore) ClaimNotifications(limit int, lease time.Duration) ([]models.OutboxNotification, error) {
+	return models.ClaimNotifications(limit, lease)
+}
+
+func (DBNotificationStore) MarkSent(n *models.OutboxNotification) error {
+	return n.MarkSent()
+}
+
+func (DBNotificationStore) MarkFailed(n *models.OutboxNotification, cause error, retryAt *time.Time, pending []string) error {
+	return n.MarkFailed(cause, retryAt, pending)
+}
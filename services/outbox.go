@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+// OutboxConfig tunes the workers that send queued notifications
+type OutboxConfig struct {
+	Workers      int
+	BatchSize    int           // notifications a worker claims at a time
+	PollInterval time.Duration // how often an idle worker looks for work
+	SendTimeout  time.Duration
+	// Lease is how long a claimed notification is reserved for its worker.
+	// It must exceed BatchSize × SendTimeout, or a slow batch could be
+	// claimed again by another worker.
+	Lease       time.Duration
+	MaxAttempts int // attempts before a notification is dead-lettered
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+var DefaultOutboxConfig = OutboxConfig{
+	Workers:      4,
+	BatchSize:    10,
+	PollInterval: time.Second,
+	SendTimeout:  15 * time.Second,
+	Lease:        5 * time.Minute,
+	MaxAttempts:  8,
+	BaseBackoff:  5 * time.Second,
+	MaxBackoff:   time.Hour,
+}
+
+// StartOutbox starts the outbox workers, which run until ctx is cancelled.
+// Every replica can run them; each notification is claimed by one worker.
+func (s *NotificationService) StartOutbox(ctx context.Context, config OutboxConfig) {
+	for i := 0; i < config.Workers; i++ {
+		go s.runOutboxWorker(ctx, config)
+	}
+}
+
+func (s *NotificationService) runOutboxWorker(ctx context.Context, config OutboxConfig) {
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep claiming without waiting while there is a backlog
+		for ctx.Err() == nil && s.processOutboxBatch(ctx, config) == config.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processOutboxBatch sends one batch of due notifications and returns how
+// many it claimed
+func (s *NotificationService) processOutboxBatch(ctx context.Context, config OutboxConfig) int {
+	batch, err := s.store.ClaimNotifications(config.BatchSize, config.Lease)
+	if err != nil {
+		log.Printf("[ERROR] Failed to claim outbox notifications: %v", err)
+		return 0
+	}
+
+	for i := range batch {
+		n := &batch[i]
+		sendCtx, cancel := context.WithTimeout(ctx, config.SendTimeout)
+		pending, err := s.deliver(sendCtx, n)
+		cancel()
+
+		if err == nil {
+			if err := s.store.MarkSent(n); err != nil {
+				log.Printf("[ERROR] Failed to mark notification %d sent: %v", n.ID, err)
+			}
+			continue
+		}
+
+		var retryAt *time.Time
+		if n.Attempts < config.MaxAttempts && !errors.Is(err, errBadPayload) {
+			at := time.Now().Add(config.backoff(n.Attempts))
+			retryAt = &at
+			log.Printf("[WARN] Notification %d failed (attempt %d), retrying at %s: %v", n.ID, n.Attempts, at.Format(time.RFC3339), err)
+		} else {
+			log.Printf("[ERROR] Notification %d failed (attempt %d), giving up: %v", n.ID, n.Attempts, err)
+		}
+		if err := s.store.MarkFailed(n, err, retryAt, pending); err != nil {
+			log.Printf("[ERROR] Failed to record failure of notification %d: %v", n.ID, err)
+		}
+	}
+	return len(batch)
+}
+
+// backoff is the wait before retrying after the given attempt: doubling from
+// BaseBackoff up to MaxBackoff, less up to 20% jitter so notifications that
+// failed together do not all retry together
+func (c OutboxConfig) backoff(attempt int) time.Duration {
+	d := c.MaxBackoff
+	if attempt < 32 {
+		if exp := c.BaseBackoff << (attempt - 1); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	return d - time.Duration(rand.Int64N(int64(d)/5+1))
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// Pusher sends push notifications to devices
+type Pusher interface {
+	// Send sends a message to one device, returning the provider's message ID
+	Send(ctx context.Context, message *messaging.Message) (string, error)
+	// SendMulticast sends a message to every token in it. The error is only
+	// set if nothing could be sent; per-token results are in the response,
+	// in token order.
+	SendMulticast(ctx context.Context, message *messaging.MulticastMessage) (*messaging.BatchResponse, error)
+}
+
+var errMessagingUnavailable = errors.New("firebase messaging is not initialized")
+
+// FirebasePusher sends through Firebase Cloud Messaging
+type FirebasePusher struct {
+	client *messaging.Client
+}
+
+// NewFirebasePusher wraps a messaging client. A nil client, e.g. when
+// Firebase failed to initialize, fails every send.
+func NewFirebasePusher(client *messaging.Client) *FirebasePusher {
+	return &FirebasePusher{client: client}
+}
+
+func (p *FirebasePusher) Send(ctx context.Context, message *messaging.Message) (string, error) {
+	if p.client == nil {
+		return "", errMessagingUnavailable
+	}
+	return p.client.Send(ctx, message)
+}
+
+// SendMulticast uses SendEachForMulticast, since the batch endpoint behind
+// the client's SendMulticast has been shut down
+func (p *FirebasePusher) SendMulticast(ctx context.Context, message *messaging.MulticastMessage) (*messaging.BatchResponse, error) {
+	if p.client == nil {
+		return nil, errMessagingUnavailable
+	}
+	return p.client.SendEachForMulticast(ctx, message)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"expvar"
+	"strings"
+	"unicode/utf8"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// pushMetrics counts what happened to each device a push was sent to. It is
+// served with the other expvars at GET /api/admin/metrics.
+var pushMetrics = expvar.NewMap("push")
+
+const (
+	metricTokensSent    = "tokens_sent"
+	metricTokensPruned  = "tokens_pruned"  // removed as no longer valid
+	metricTokensRetried = "tokens_retried" // failed temporarily and queued for another attempt
+	metricTokensFailed  = "tokens_failed"  // failed for a reason a retry would not fix
+)
+
+// tokenAction is what to do about a device a push failed to reach
+type tokenAction int
+
+const (
+	// the token will never work again, so it is deleted
+	tokenPrune tokenAction = iota
+	// FCM or APNs is overloaded or down, so the send is tried again later
+	tokenRetry
+	// something else is wrong, e.g. our credentials; the token is kept
+	// since it may be fine, but retrying would fail the same way
+	tokenKeep
+	// FCM rejected the message itself, e.g. as too large; the token is
+	// kept and the notification dead-lettered, since every attempt and
+	// every device would fail the same way
+	tokenBadPayload
+)
+
+// errBadPayload marks a delivery that failed because of the notification
+// itself, so the outbox gives up on it without retrying
+var errBadPayload = errors.New("FCM rejected the notification")
+
+// classifyPushError decides what to do about a device from the error FCM
+// returned for it. INVALID_ARGUMENT covers both malformed tokens and
+// malformed messages, so only the former, which FCM describes as an
+// invalid registration token, prunes the token.
+func classifyPushError(err error) tokenAction {
+	switch {
+	case messaging.IsUnregistered(err), messaging.IsSenderIDMismatch(err):
+		return tokenPrune
+	case messaging.IsInvalidArgument(err):
+		if strings.Contains(strings.ToLower(err.Error()), "registration token") {
+			return tokenPrune
+		}
+		return tokenBadPayload
+	case messaging.IsQuotaExceeded(err), messaging.IsMessageRateExceeded(err),
+		messaging.IsUnavailable(err), messaging.IsInternal(err):
+		return tokenRetry
+	default:
+		return tokenKeep
+	}
+}
+
+// maxPushBodyBytes keeps notification bodies well under FCM's 4KB payload
+// limit, so long messages are shortened rather than rejected
+const maxPushBodyBytes = 1024
+
+// truncateBody shortens body to maxPushBodyBytes without splitting a rune
+func truncateBody(body string) string {
+	if len(body) <= maxPushBodyBytes {
+		return body
+	}
+	cut := maxPushBodyBytes - len("…")
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut] + "…"
+}